@@ -0,0 +1,128 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Returns the path to save the diagnostics archive for the provided report at.
+func diagnosticsPath(reportPath string) string {
+	return strings.TrimSuffix(reportPath, filepath.Ext(reportPath)) + "-diagnostics.zip"
+}
+
+// Gathers the support bundle, plugin configuration, scheduled task history, newest server log and (optionally) the
+// report into a zip archive. Collection is best effort: any file which cannot be retrieved is listed in errors.txt.
+func collectDiagnostics(hostAddress, apiKey, destination string, report []byte) {
+	logger.Infof("Collecting diagnostics")
+
+	files := make(map[string][]byte)
+	var errors []string
+
+	// Stores the body of a request in the archive, recording any error that occurred.
+	save := func(name, path string) []byte {
+		body, err := tryRequest("GET", hostAddress+path, apiKey)
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %s", name, err))
+			return nil
+		}
+
+		files[name] = body
+		return body
+	}
+
+	// Support bundle, both as returned by the server and parsed into structured fields
+	if raw := save("support_bundle.md", "/IntroSkipper/SupportBundle"); raw != nil {
+		if bundle, err := parseSupportBundle(string(raw)); err != nil {
+			errors = append(errors, fmt.Sprintf("support_bundle.json: %s", err))
+		} else {
+			files["support_bundle.json"] = marshalIndent(bundle)
+		}
+	}
+
+	save("plugin_configuration.json", "/Plugins/c83d86bb-a1e0-4c35-a113-e2101cf4ee6b/Configuration")
+	save("scheduled_tasks.json", "/ScheduledTasks")
+
+	// Only the most recently modified server log is included
+	if raw := save("server_logs.json", "/System/Logs"); raw != nil {
+		var logs []struct {
+			Name         string
+			DateModified time.Time
+		}
+
+		if err := json.Unmarshal(raw, &logs); err != nil {
+			errors = append(errors, fmt.Sprintf("server_logs.json: %s", err))
+		} else if len(logs) > 0 {
+			sort.Slice(logs, func(i, j int) bool { return logs[i].DateModified.After(logs[j].DateModified) })
+			name := filepath.Base(logs[0].Name)
+			save("logs/"+name, "/System/Logs/Log?name="+url.QueryEscape(name))
+		}
+	}
+
+	if report != nil {
+		files["report.json"] = report
+	}
+
+	if len(errors) > 0 {
+		files["errors.txt"] = []byte(strings.Join(errors, "\n") + "\n")
+	}
+
+	if err := writeZip(destination, files); err != nil {
+		panic(err)
+	}
+
+	// Change archive permissions
+	exec.Command("chown", "1000:1000", destination).Run()
+
+	logger.Infof("Saved diagnostics to %s", destination)
+	for _, e := range errors {
+		logger.Warnf("Unable to collect %s", e)
+	}
+}
+
+// Writes all provided files into a new zip archive.
+func writeZip(destination string, files map[string][]byte) error {
+	f, err := os.OpenFile(destination, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Sort the filenames so that archives are reproducible
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	archive := zip.NewWriter(f)
+	for _, name := range names {
+		w, err := archive.Create(name)
+		if err != nil {
+			return err
+		}
+
+		if _, err := w.Write(files[name]); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+// Marshals the provided value as indented JSON or panics.
+func marshalIndent(v interface{}) []byte {
+	marshalled, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	return marshalled
+}
@@ -24,18 +24,16 @@ func SendRequest(method, url, apiKey string) []byte {
 	// Include the authorization token
 	req.Header.Set("Authorization", fmt.Sprintf(`MediaBrowser Token="%s"`, apiKey))
 
-	// Send the request
+	// Send the request and panic if any error occurred
 	res, err := http.DefaultClient.Do(req)
-
-	if !strings.Contains(url, "hideUrl") {
-		fmt.Printf("[+] %s %s: %d\n", method, url, res.StatusCode)
-	}
-
-	// Panic if any error occurred
 	if err != nil {
 		panic(err)
 	}
 
+	if !strings.Contains(url, "hideUrl") {
+		logger.Debugf("%s %s: %d", method, url, res.StatusCode)
+	}
+
 	// Check for API key validity
 	if res.StatusCode == http.StatusUnauthorized {
 		panic("Server returned 401 (Unauthorized). Check API key validity and try again.")
@@ -51,10 +49,21 @@ func SendRequest(method, url, apiKey string) []byte {
 	return body
 }
 
+// Gets the contents of the provided URL, returning an error instead of panicking.
+func tryRequest(method, url, apiKey string) (body []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	return SendRequest(method, url, apiKey), nil
+}
+
 func GetServerInfo(hostAddress, apiKey string) structs.PublicInfo {
 	var info structs.PublicInfo
 
-	fmt.Println("[+] Getting server information")
+	logger.Infof("Getting server information")
 	rawInfo := SendRequest("GET", hostAddress+"/System/Info/Public", apiKey)
 
 	if err := json.Unmarshal(rawInfo, &info); err != nil {
@@ -67,7 +76,7 @@ func GetServerInfo(hostAddress, apiKey string) structs.PublicInfo {
 func GetPluginConfiguration(hostAddress, apiKey string) structs.PluginConfiguration {
 	var config structs.PluginConfiguration
 
-	fmt.Println("[+] Getting plugin configuration")
+	logger.Infof("Getting plugin configuration")
 	rawConfig := SendRequest("GET", hostAddress+"/Plugins/c83d86bb-a1e0-4c35-a113-e2101cf4ee6b/Configuration", apiKey)
 
 	if err := json.Unmarshal(rawConfig, &config); err != nil {
@@ -76,3 +85,99 @@ func GetPluginConfiguration(hostAddress, apiKey string) structs.PluginConfigurat
 
 	return config
 }
+
+// Number of item ids to request details for at once.
+const itemsBatchSize = 50
+
+// Looks up the media details of the provided episodes using the Items API.
+// The returned map is keyed by normalized item id.
+func GetMediaDetails(hostAddress, apiKey string, ids []string) map[string]structs.MediaDetails {
+	type mediaStream struct {
+		Type     string
+		Index    int
+		Codec    string
+		Channels int
+	}
+
+	type mediaSource struct {
+		Container               string
+		DefaultAudioStreamIndex *int
+		MediaStreams            []mediaStream
+	}
+
+	type item struct {
+		Id           string
+		Path         string
+		Container    string
+		RunTimeTicks int64
+		MediaSources []mediaSource
+		Chapters     []structs.Chapter
+	}
+
+	type itemsResponse struct {
+		Items []item
+	}
+
+	details := make(map[string]structs.MediaDetails)
+
+	logger.Infof("Looking up media details for %d episodes", len(ids))
+
+	for start := 0; start < len(ids); start += itemsBatchSize {
+		end := start + itemsBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		url := fmt.Sprintf(
+			"%s/Items?ids=%s&fields=Path,MediaSources,Chapters&enableImages=false&hideUrl=1",
+			hostAddress,
+			strings.Join(ids[start:end], ","))
+
+		var response itemsResponse
+		if err := json.Unmarshal(SendRequest("GET", url, apiKey), &response); err != nil {
+			panic(err)
+		}
+
+		for _, item := range response.Items {
+			media := structs.MediaDetails{
+				RunTimeTicks: item.RunTimeTicks,
+				Path:         item.Path,
+				Container:    item.Container,
+				Chapters:     item.Chapters,
+			}
+
+			// Use the default audio stream of the first media source, falling back to the first audio stream
+			if len(item.MediaSources) > 0 {
+				source := item.MediaSources[0]
+				if media.Container == "" {
+					media.Container = source.Container
+				}
+
+				for _, stream := range source.MediaStreams {
+					if stream.Type != "Audio" {
+						continue
+					}
+
+					isDefault := source.DefaultAudioStreamIndex != nil && *source.DefaultAudioStreamIndex == stream.Index
+					if media.AudioCodec == "" || isDefault {
+						media.AudioCodec = stream.Codec
+						media.AudioChannels = stream.Channels
+					}
+
+					if isDefault {
+						break
+					}
+				}
+			}
+
+			details[normalizeId(item.Id)] = media
+		}
+	}
+
+	return details
+}
+
+// Normalizes an item id by removing dashes and converting it to lowercase.
+func normalizeId(id string) string {
+	return strings.ToLower(strings.ReplaceAll(id, "-", ""))
+}
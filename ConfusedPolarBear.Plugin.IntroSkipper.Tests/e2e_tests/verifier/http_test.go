@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetMediaDetails(t *testing.T) {
+	var requests int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		if ids := strings.Split(r.URL.Query().Get("ids"), ","); len(ids) > itemsBatchSize {
+			t.Errorf("requested %d ids in a single batch", len(ids))
+		}
+
+		w.Write([]byte(`{"Items":[{
+			"Id": "0123456789abcdef0123456789abcdef",
+			"Path": "/media/TV/Show/S01E01.mkv",
+			"Container": "mkv",
+			"RunTimeTicks": 14400000000,
+			"MediaSources": [{
+				"DefaultAudioStreamIndex": 2,
+				"MediaStreams": [
+					{"Type": "Video", "Index": 0, "Codec": "h264"},
+					{"Type": "Audio", "Index": 1, "Codec": "aac", "Channels": 2},
+					{"Type": "Audio", "Index": 2, "Codec": "ac3", "Channels": 6}
+				]
+			}],
+			"Chapters": [{"Name": "Opening", "StartPositionTicks": 0}]
+		}]}`))
+	}))
+	defer server.Close()
+
+	ids := make([]string, itemsBatchSize+1)
+	ids[0] = "01234567-89AB-CDEF-0123-456789ABCDEF"
+
+	details := GetMediaDetails(server.URL, "key", ids)
+
+	if requests != 2 {
+		t.Errorf("expected ids to be looked up in 2 batches, found %d", requests)
+	}
+
+	media, ok := details[normalizeId(ids[0])]
+	if !ok {
+		t.Fatalf("media details were not found for %s", ids[0])
+	}
+
+	if media.Duration() != 1440 {
+		t.Errorf("incorrect episode duration %0.2f", media.Duration())
+	}
+
+	if media.Container != "mkv" || media.Path != "/media/TV/Show/S01E01.mkv" {
+		t.Errorf("incorrect container or path: %+v", media)
+	}
+
+	if media.AudioCodec != "ac3" || media.AudioChannels != 6 {
+		t.Errorf("default audio stream was not used: %+v", media)
+	}
+
+	if len(media.Chapters) != 1 || media.Chapters[0].Name != "Opening" {
+		t.Errorf("incorrect chapters: %+v", media.Chapters)
+	}
+}
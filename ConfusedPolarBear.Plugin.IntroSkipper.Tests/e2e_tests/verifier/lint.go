@@ -0,0 +1,157 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/confusedpolarbear/intro_skipper_verifier/structs"
+)
+
+// A plugin invariant that was violated by a timestamp in a report.
+type lintIssue struct {
+	// Segment the issue was found in.
+	Segment structs.Intro
+
+	// Recognized issue types:
+	//   * intro_too_long:    intro is longer than MaximumIntroDuration
+	//   * intro_too_short:   intro is shorter than MinimumIntroDuration
+	//   * intro_outside:     intro ends after the analyzed portion of the episode
+	//   * credits_too_long:  credits are longer than MaximumEpisodeCreditsDuration
+	//   * credits_outside:   credits start before the analyzed portion of the episode
+	//   * overlap:           intro and credits overlap
+	Kind string
+
+	// Description of the issue.
+	Message string
+}
+
+// Parses the arguments to the lint subcommand and lints all provided reports.
+func lintCommand(args []string) {
+	lintFlags := flag.NewFlagSet("lint", flag.ExitOnError)
+	lintFlags.Usage = func() {
+		lintFlags.Output().Write([]byte("Usage: ./verifier lint report.json [report2.json ...]\n"))
+		lintFlags.PrintDefaults()
+	}
+
+	addLoggingFlags(lintFlags)
+	lintFlags.Parse(args)
+	configureLogging()
+
+	if lintFlags.NArg() == 0 {
+		lintFlags.Usage()
+		os.Exit(2)
+	}
+
+	total := 0
+	for _, path := range lintFlags.Args() {
+		total += lintReportFile(path)
+	}
+
+	if total > 0 {
+		logger.Errorf("Found %d issues", total)
+		os.Exit(1)
+	}
+
+	logger.Infof("No issues found")
+}
+
+// Lints the report at the provided path, printing and returning the number of issues found.
+func lintReportFile(path string) int {
+	report := unmarshalReport(path)
+	issues := lintReport(report)
+
+	for _, issue := range issues {
+		segment := issue.Segment
+		logger.Errorf("%s S%02d %s (%s): %s: %s",
+			segment.Series,
+			segment.Season,
+			segment.Title,
+			segment.EpisodeId,
+			issue.Kind,
+			issue.Message)
+	}
+
+	if len(issues) > 0 {
+		logger.Println()
+	}
+
+	return len(issues)
+}
+
+// Checks every intro and credit in the report against the limits in the report's plugin configuration.
+func lintReport(report structs.Report) []lintIssue {
+	var issues []lintIssue
+	config := report.PluginConfig
+
+	addIssue := func(segment structs.Intro, kind, format string, args ...interface{}) {
+		issues = append(issues, lintIssue{
+			Segment: segment,
+			Kind:    kind,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	introsById := make(map[string]structs.Intro)
+
+	for _, intro := range report.Intros {
+		if !intro.Valid {
+			continue
+		}
+
+		introsById[intro.EpisodeId] = intro
+		duration := intro.IntroEnd - intro.IntroStart
+
+		if limit := float32(config.MaximumIntroDuration); limit > 0 && duration > limit {
+			addIssue(intro, "intro_too_long", "duration %0.2fs is longer than the maximum of %0.fs", duration, limit)
+		}
+
+		if limit := float32(config.MinimumIntroDuration); duration < limit {
+			addIssue(intro, "intro_too_short", "duration %0.2fs is shorter than the minimum of %0.fs", duration, limit)
+		}
+
+		if window := analysisWindow(config, intro.EpisodeDuration); window > 0 && intro.IntroEnd > window {
+			addIssue(intro, "intro_outside", "intro ends at %0.2fs but only the first %0.2fs are analyzed", intro.IntroEnd, window)
+		}
+	}
+
+	for _, credits := range report.Credits {
+		if !credits.Valid {
+			continue
+		}
+
+		duration := credits.IntroEnd - credits.IntroStart
+		limit := float32(config.MaximumEpisodeCreditsDuration)
+
+		if limit > 0 && duration > limit {
+			addIssue(credits, "credits_too_long", "duration %0.2fs is longer than the maximum of %0.fs", duration, limit)
+		}
+
+		if runtime := credits.EpisodeDuration; limit > 0 && runtime > 0 && credits.IntroStart < runtime-limit {
+			addIssue(credits, "credits_outside", "credits start at %0.2fs but only the last %0.fs are analyzed", credits.IntroStart, limit)
+		}
+
+		intro, ok := introsById[credits.EpisodeId]
+		if ok && intro.IntroStart < credits.IntroEnd && credits.IntroStart < intro.IntroEnd {
+			addIssue(credits, "overlap", "intro (%0.2fs - %0.2fs) overlaps credits (%0.2fs - %0.2fs)",
+				intro.IntroStart, intro.IntroEnd, credits.IntroStart, credits.IntroEnd)
+		}
+	}
+
+	return issues
+}
+
+// Returns the number of seconds at the start of an episode that are analyzed when searching for an introduction.
+// If the episode duration is unknown, only the analysis length limit is considered.
+func analysisWindow(config structs.PluginConfiguration, episodeDuration float32) float32 {
+	window := float32(config.AnalysisLengthLimit * 60)
+
+	if episodeDuration > 0 {
+		percent := episodeDuration * float32(config.AnalysisPercent) / 100
+		if window == 0 || percent < window {
+			window = percent
+		}
+	}
+
+	return window
+}
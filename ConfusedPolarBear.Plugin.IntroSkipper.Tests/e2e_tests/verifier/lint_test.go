@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/confusedpolarbear/intro_skipper_verifier/structs"
+)
+
+func TestLintReport(t *testing.T) {
+	report := structs.Report{
+		PluginConfig: structs.PluginConfiguration{
+			AnalysisPercent:               25,
+			AnalysisLengthLimit:           10,
+			MinimumIntroDuration:          15,
+			MaximumIntroDuration:          120,
+			MaximumEpisodeCreditsDuration: 240,
+		},
+		Intros: []structs.Intro{
+			{EpisodeId: "okay", IntroStart: 10, IntroEnd: 40, Valid: true, EpisodeDuration: 1200},
+			{EpisodeId: "long", IntroStart: 0, IntroEnd: 150, Valid: true},
+			{EpisodeId: "short", IntroStart: 0, IntroEnd: 5, Valid: true},
+			{EpisodeId: "outside", IntroStart: 280, IntroEnd: 310, Valid: true, EpisodeDuration: 1200},
+			{EpisodeId: "overlap", IntroStart: 100, IntroEnd: 130, Valid: true},
+			{EpisodeId: "invalid", Valid: false},
+		},
+		Credits: []structs.Intro{
+			{EpisodeId: "okay", IntroStart: 1100, IntroEnd: 1180, Valid: true, EpisodeDuration: 1200},
+			{EpisodeId: "credits_long", IntroStart: 1000, IntroEnd: 1300, Valid: true},
+			{EpisodeId: "credits_outside", IntroStart: 700, IntroEnd: 760, Valid: true, EpisodeDuration: 1200},
+			{EpisodeId: "overlap", IntroStart: 120, IntroEnd: 180, Valid: true},
+		},
+	}
+
+	expected := map[string]string{
+		"long":            "intro_too_long",
+		"short":           "intro_too_short",
+		"outside":         "intro_outside",
+		"credits_long":    "credits_too_long",
+		"credits_outside": "credits_outside",
+		"overlap":         "overlap",
+	}
+
+	issues := lintReport(report)
+	if len(issues) != len(expected) {
+		t.Fatalf("expected %d issues, found %d: %v", len(expected), len(issues), issues)
+	}
+
+	for _, issue := range issues {
+		if kind := expected[issue.Segment.EpisodeId]; kind != issue.Kind {
+			t.Errorf("episode %s was flagged as %s, expected %s", issue.Segment.EpisodeId, issue.Kind, kind)
+		}
+	}
+}
+
+func TestAnalysisWindow(t *testing.T) {
+	config := structs.PluginConfiguration{AnalysisPercent: 25, AnalysisLengthLimit: 10}
+
+	if window := analysisWindow(config, 1200); window != 300 {
+		t.Errorf("window for a 20 minute episode should be limited by the percentage, found %0.2f", window)
+	}
+
+	if window := analysisWindow(config, 3600); window != 600 {
+		t.Errorf("window for an hour long episode should be limited by the length limit, found %0.2f", window)
+	}
+
+	if window := analysisWindow(config, 0); window != 600 {
+		t.Errorf("window for an episode of unknown length should be the length limit, found %0.2f", window)
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Severity of a logged message.
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < 0 || int(l) >= len(levelNames) {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+
+	return levelNames[l]
+}
+
+// Parses a level name. Used as a flag.Value.
+func (l *Level) Set(name string) error {
+	for i, levelName := range levelNames {
+		if strings.EqualFold(name, levelName) {
+			*l = Level(i)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown log level %q, must be one of %s", name, strings.Join(levelNames, ", "))
+}
+
+// Markers shown before messages of each level in text output.
+var levelMarkers = []string{"[.]", "[+]", "[!]", "[!]"}
+
+// ANSI colors used for the marker of each level when color is enabled.
+var levelColors = []string{"\x1b[90m", "\x1b[32m", "\x1b[33m", "\x1b[31m"}
+
+const colorReset = "\x1b[0m"
+
+// Format of all logged output.
+type LogFormat string
+
+const (
+	// Human readable lines prefixed with a marker for the level.
+	FormatText LogFormat = "text"
+
+	// One JSON object per line, for parsing in CI or by the wrapper.
+	FormatJSON LogFormat = "json"
+)
+
+func (f LogFormat) String() string {
+	return string(f)
+}
+
+// Parses a log format. Used as a flag.Value.
+func (f *LogFormat) Set(name string) error {
+	switch LogFormat(name) {
+	case FormatText, FormatJSON:
+		*f = LogFormat(name)
+		return nil
+	}
+
+	return fmt.Errorf("unknown log format %q, must be text or json", name)
+}
+
+// When to colorize text output.
+type ColorMode string
+
+const (
+	ColorAuto   ColorMode = "auto"
+	ColorAlways ColorMode = "always"
+	ColorNever  ColorMode = "never"
+)
+
+func (c ColorMode) String() string {
+	return string(c)
+}
+
+// Parses a color mode. Used as a flag.Value.
+func (c *ColorMode) Set(name string) error {
+	switch ColorMode(name) {
+	case ColorAuto, ColorAlways, ColorNever:
+		*c = ColorMode(name)
+		return nil
+	}
+
+	return fmt.Errorf("unknown color mode %q, must be auto, always or never", name)
+}
+
+// Logging options, set from the command line.
+var (
+	logLevel  = LevelInfo
+	logFormat = FormatText
+	logColor  = ColorAuto
+)
+
+// Set by configureLogging if text output is colorized.
+var colorEnabled bool
+
+// Set by configureLogging if stdout is a terminal that progress can be redrawn on.
+var interactive bool
+
+// Destination of all logged output.
+var logOutput io.Writer = os.Stdout
+
+// Registers the logging flags on a flag set.
+func addLoggingFlags(flags *flag.FlagSet) {
+	flags.Var(&logLevel, "log-level", "Minimum level of messages to log. One of debug, info, warn or error.")
+	flags.Var(&logFormat, "log-format", "Log format. Either text or json (one JSON object per line).")
+	flags.Var(&logColor, "color", "Colorize output. One of auto, always or never. auto only colorizes output to a terminal.")
+}
+
+// Resolves the color mode and whether progress is redrawn in place. In auto mode, output is only colorized if stdout
+// is a terminal and NO_COLOR is unset.
+func configureLogging() {
+	terminal := isTerminal(os.Stdout) && os.Getenv("TERM") != "dumb"
+	interactive = terminal && logFormat == FormatText
+
+	switch logColor {
+	case ColorAlways:
+		colorEnabled = true
+
+	case ColorNever:
+		colorEnabled = false
+
+	default:
+		colorEnabled = terminal && os.Getenv("NO_COLOR") == ""
+	}
+}
+
+// Returns true if the file is a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// A single logged message. In JSON output, every record is written as one line.
+type logRecord struct {
+	Time    string `json:"time"`
+	Level   string `json:"level"`
+	Message string `json:"msg"`
+}
+
+// Writes messages to stdout.
+type Logger struct{}
+
+// Logger for all output.
+var logger = &Logger{}
+
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	l.log(LevelDebug, true, fmt.Sprintf(format, args...))
+}
+
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.log(LevelInfo, true, fmt.Sprintf(format, args...))
+}
+
+func (l *Logger) Warnf(format string, args ...interface{}) {
+	l.log(LevelWarn, true, fmt.Sprintf(format, args...))
+}
+
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.log(LevelError, true, fmt.Sprintf(format, args...))
+}
+
+// Logs preformatted informational text, such as server details, without a level marker.
+func (l *Logger) Printf(format string, args ...interface{}) {
+	l.log(LevelInfo, false, fmt.Sprintf(format, args...))
+}
+
+// Logs preformatted informational text without a level marker. Without arguments, logs a blank line.
+func (l *Logger) Println(args ...interface{}) {
+	l.log(LevelInfo, false, fmt.Sprintln(args...))
+}
+
+// Writes every line in text, if its level is enabled. A trailing newline is ignored.
+func (l *Logger) log(level Level, marked bool, text string) {
+	if level < logLevel {
+		return
+	}
+
+	record := logRecord{
+		Time:  time.Now().Format(time.RFC3339Nano),
+		Level: level.String(),
+	}
+
+	var out strings.Builder
+
+	for _, line := range strings.SplitAfter(strings.TrimSuffix(text, "\n"), "\n") {
+		record.Message = strings.TrimSuffix(line, "\n")
+
+		if logFormat == FormatJSON {
+			// Blank lines only separate sections of text output
+			if strings.TrimSpace(record.Message) == "" {
+				continue
+			}
+
+			raw, err := json.Marshal(record)
+			if err != nil {
+				panic(err)
+			}
+
+			out.Write(raw)
+			out.WriteString("\n")
+			continue
+		}
+
+		if marked {
+			marker := levelMarkers[level]
+			if colorEnabled {
+				marker = levelColors[level] + marker + colorReset
+			}
+
+			out.WriteString(marker + " ")
+		}
+
+		out.WriteString(record.Message + "\n")
+	}
+
+	io.WriteString(logOutput, out.String())
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// Captures all logged output with the provided options until the test finishes.
+func captureLog(t *testing.T, level Level, format LogFormat) *bytes.Buffer {
+	var buf bytes.Buffer
+
+	oldOutput, oldLevel, oldFormat, oldColor := logOutput, logLevel, logFormat, colorEnabled
+	t.Cleanup(func() { logOutput, logLevel, logFormat, colorEnabled = oldOutput, oldLevel, oldFormat, oldColor })
+
+	logOutput, logLevel, logFormat, colorEnabled = &buf, level, format, false
+
+	return &buf
+}
+
+func TestLoggerText(t *testing.T) {
+	buf := captureLog(t, LevelInfo, FormatText)
+
+	logger.Debugf("GET /System/Info: 200")
+	logger.Infof("Getting server information")
+	logger.Printf("Jellyfin version:  %s\n\n", "10.8.9")
+	logger.Errorf("Found %d issues", 2)
+
+	expected := "[+] Getting server information\nJellyfin version:  10.8.9\n\n[!] Found 2 issues\n"
+	if buf.String() != expected {
+		t.Errorf("incorrect output: %q", buf.String())
+	}
+}
+
+func TestLoggerJSON(t *testing.T) {
+	buf := captureLog(t, LevelDebug, FormatJSON)
+
+	logger.Debugf("GET /System/Info: 200")
+	logger.Println()
+	logger.Warnf("Unable to collect logs")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("incorrect number of lines: %q", buf.String())
+	}
+
+	var record logRecord
+	if err := json.Unmarshal([]byte(lines[1]), &record); err != nil {
+		t.Fatal(err)
+	}
+
+	if record.Level != "warn" || record.Message != "Unable to collect logs" || record.Time == "" {
+		t.Errorf("incorrect record: %+v", record)
+	}
+}
@@ -2,7 +2,10 @@ package main
 
 import (
 	"flag"
+	"os"
 	"time"
+
+	"github.com/confusedpolarbear/intro_skipper_verifier/structs"
 )
 
 func flags() {
@@ -12,14 +15,29 @@ func flags() {
 	keepTimestamps := flag.Bool("keep", false, "Keep the current timestamps instead of erasing and reanalyzing.")
 	pollInterval := flag.Duration("poll", 10*time.Second, "Interval to poll task completion at.")
 	reportDestination := flag.String("o", "", "Report destination filename. Defaults to intros-ADDRESS-TIMESTAMP.json.")
+	checkBundle := flag.Bool("bundle", false, "Check the plugin's support bundle for warnings and FFmpeg incompatibilities instead of analyzing.")
+	pluginVersion := flag.String("plugin-version", "", "Plugin version that the support bundle must report. Only used with -bundle.")
+	diagnosticsOnly := flag.Bool("diagnostics", false, "Only collect a diagnostics archive for the report destination, without analyzing.")
 
 	// Report comparison
 	report1 := flag.String("r1", "", "First report.")
 	report2 := flag.String("r2", "", "Second report.")
+	format := flag.String("format", "html", "Comparison output format. Either html or markdown.")
+	tolerance := flag.Int("tolerance", 5, "Maximum number of seconds that timestamps can differ by before they are considered different.")
+	maxLost := flag.Int("max-lost", -1, "Fail if more introductions than this were lost. Negative values disable the check.")
+	maxChanged := flag.Int("max-changed", -1, "Fail if more introductions than this changed. Negative values disable the check.")
+	maxGained := flag.Int("max-gained", -1, "Fail if more introductions than this were gained. Negative values disable the check.")
+	minOkay := flag.Float64("min-okay", 0, "Fail if less than this percentage of episodes are okay or improved.")
+	maxRuntimeIncrease := flag.Float64("max-runtime-increase", -1, "Fail if the analysis runtime increased by more than this percentage. Negative values disable the check.")
+	maxCPUIncrease := flag.Float64("max-cpu-increase", -1, "Fail if the average CPU usage during analysis increased by more than this percentage. Negative values disable the check.")
+	maxMemoryIncrease := flag.Float64("max-memory-increase", -1, "Fail if the peak memory usage during analysis increased by more than this percentage. Negative values disable the check.")
 
 	// API schema validator
 	ids := flag.String("validate", "", "Comma separated item ids to validate the API schema for.")
 
+	// Output
+	addLoggingFlags(flag.CommandLine)
+
 	// Print usage examples
 	flag.CommandLine.Usage = func() {
 		flag.CommandLine.Output().Write([]byte("Flags:\n"))
@@ -35,23 +53,65 @@ func flags() {
 			"Compare two previously generated reports:\n" +
 			"./verifier -r1 v0.1.5.json -r2 v0.1.6.json\n\n" +
 
+			"Compare two reports, failing if any introductions were lost or less than 95% of episodes are okay:\n" +
+			"./verifier -r1 v0.1.5.json -r2 v0.1.6.json -max-lost 0 -min-okay 95\n\n" +
+
+			"Compare two reports, failing if analysis became more than 10% slower or used 20% more memory:\n" +
+			"./verifier -r1 v0.1.5.json -r2 v0.1.6.json -max-runtime-increase 10 -max-memory-increase 20\n\n" +
+
+			"Summarize the differences between two reports as Markdown:\n" +
+			"./verifier -r1 v0.1.5.json -r2 v0.1.6.json -format markdown -o summary.md\n\n" +
+
+			"Collect a diagnostics archive (saved as example-diagnostics.zip) from a server without analyzing:\n" +
+			"./verifier -address http://127.0.0.1:8096 -key api_key -diagnostics -o example.json\n\n" +
+
+			"Check that the support bundle has no warnings and reports plugin version 0.1.8:\n" +
+			"./verifier -address http://127.0.0.1:8096 -key api_key -bundle -plugin-version 0.1.8\n\n" +
+
+			"Generate a report, logging one JSON object per line for CI:\n" +
+			"./verifier -address http://127.0.0.1:8096 -key api_key -log-format json\n\n" +
+
 			"Validate the API schema for some item ids:\n" +
-			"./verifier -address http://127.0.0.1:8096 -key api_key -validate id1,id2,id3\n"
+			"./verifier -address http://127.0.0.1:8096 -key api_key -validate id1,id2,id3\n\n" +
+
+			"Check a report against the limits in its plugin configuration:\n" +
+			"./verifier lint v0.1.6.json\n"
 
 		flag.CommandLine.Output().Write([]byte(usage))
 	}
 
 	flag.Parse()
+	configureLogging()
 
 	if *hostAddress != "" && *apiKey != "" {
-		if *ids == "" {
+		if *checkBundle {
+			verifySupportBundle(*hostAddress, *apiKey, *pluginVersion)
+		} else if *diagnosticsOnly {
+			if *reportDestination == "" {
+				panic("-diagnostics requires -o")
+			}
+
+			collectDiagnostics(*hostAddress, *apiKey, diagnosticsPath(*reportDestination), nil)
+		} else if *ids == "" {
 			generateReport(*hostAddress, *apiKey, *reportDestination, *keepTimestamps, *pollInterval)
 		} else {
 			validateApiSchema(*hostAddress, *apiKey, *ids)
 		}
 
 	} else if *report1 != "" && *report2 != "" {
-		compareReports(*report1, *report2, *reportDestination)
+		comparisonTolerance = *tolerance
+		thresholds := structs.ComparisonThresholds{
+			MaxLost:        *maxLost,
+			MaxChanged:     *maxChanged,
+			MaxGained:      *maxGained,
+			MinOkayPercent: *minOkay,
+
+			MaxRuntimeIncrease: *maxRuntimeIncrease,
+			MaxCPUIncrease:     *maxCPUIncrease,
+			MaxMemoryIncrease:  *maxMemoryIncrease,
+		}
+
+		compareReports(*report1, *report2, *reportDestination, *format, thresholds)
 
 	} else {
 		panic("Either (-address and -key) or (-r1 and -r2) are required.")
@@ -59,5 +119,11 @@ func flags() {
 }
 
 func main() {
+	// Subcommands are dispatched before any flags are parsed
+	if len(os.Args) > 1 && os.Args[1] == "lint" {
+		lintCommand(os.Args[2:])
+		return
+	}
+
 	flags()
 }
@@ -15,12 +15,21 @@ import (
 //go:embed report.html
 var reportTemplate []byte
 
-func compareReports(oldReportPath, newReportPath, destination string) {
+func compareReports(oldReportPath, newReportPath, destination, format string, thresholds structs.ComparisonThresholds) {
 	start := time.Now()
 
+	if format != "html" && format != "markdown" {
+		panic(fmt.Sprintf("Unknown comparison format '%s'. Supported formats are html and markdown.", format))
+	}
+
 	// Populate the destination filename if none was provided
 	if destination == "" {
-		destination = fmt.Sprintf("report-%d.html", start.Unix())
+		extension := "html"
+		if format == "markdown" {
+			extension = "md"
+		}
+
+		destination = fmt.Sprintf("report-%d.%s", start.Unix(), extension)
 	}
 
 	// Open the report for writing
@@ -31,15 +40,31 @@ func compareReports(oldReportPath, newReportPath, destination string) {
 		defer f.Close()
 	}
 
-	fmt.Printf("Started at:    %s\n", start.Format(time.RFC1123))
-	fmt.Printf("First report:  %s\n", oldReportPath)
-	fmt.Printf("Second report: %s\n", newReportPath)
-	fmt.Printf("Destination:   %s\n\n", destination)
+	logger.Printf("Started at:    %s\n", start.Format(time.RFC1123))
+	logger.Printf("First report:  %s\n", oldReportPath)
+	logger.Printf("Second report: %s\n", newReportPath)
+	logger.Printf("Destination:   %s\n\n", destination)
 
 	// Unmarshal both reports
 	oldReport, newReport := unmarshalReport(oldReportPath), unmarshalReport(newReportPath)
 
-	fmt.Println("[+] Comparing reports")
+	logger.Infof("Comparing reports")
+
+	data := structs.TemplateReportData{
+		OldReport: oldReport,
+		NewReport: newReport,
+	}
+
+	// Markdown summaries are written directly instead of through the HTML template
+	if format == "markdown" {
+		if err := writeMarkdownSummary(f, data); err != nil {
+			panic(err)
+		}
+
+		logger.Infof("Reports successfully compared in %s", time.Since(start).Round(time.Millisecond))
+		enforceThresholds(data, thresholds)
+		return
+	}
 
 	// Setup a function map with helper functions to use in the template
 	tmp := template.New("report")
@@ -54,6 +79,8 @@ func compareReports(oldReportPath, newReportPath, destination string) {
 		return d.Round(time.Second).String()
 	}
 
+	funcs["printBytes"] = formatBytes
+
 	funcs["printAnalysisSettings"] = func(pc structs.PluginConfiguration) string {
 		return pc.AnalysisSettings()
 	}
@@ -70,18 +97,99 @@ func compareReports(oldReportPath, newReportPath, destination string) {
 	// Load the template or panic
 	report := template.Must(tmp.Parse(string(reportTemplate)))
 
-	err = report.Execute(f,
-		structs.TemplateReportData{
-			OldReport: oldReport,
-			NewReport: newReport,
-		})
-
-	if err != nil {
+	if err := report.Execute(f, data); err != nil {
 		panic(err)
 	}
 
 	// Log success
-	fmt.Printf("[+] Reports successfully compared in %s\n", time.Since(start).Round(time.Millisecond))
+	logger.Infof("Reports successfully compared in %s", time.Since(start).Round(time.Millisecond))
+	enforceThresholds(data, thresholds)
+}
+
+// Checks the comparison against the provided thresholds, exiting with an error if any are exceeded.
+func enforceThresholds(reports structs.TemplateReportData, thresholds structs.ComparisonThresholds) {
+	violations := checkThresholds(compareAllEpisodes(reports), thresholds)
+	violations = append(violations, checkResourceThresholds(reports.OldReport, reports.NewReport, thresholds)...)
+	if len(violations) == 0 {
+		return
+	}
+
+	logger.Println()
+	for _, violation := range violations {
+		logger.Errorf("%s", violation)
+	}
+
+	os.Exit(1)
+}
+
+// Returns a description of every threshold exceeded by the provided comparison.
+func checkThresholds(pairs []structs.IntroPair, thresholds structs.ComparisonThresholds) []string {
+	var violations []string
+
+	counts := make(map[string]int)
+	for _, pair := range pairs {
+		counts[pair.WarningShort]++
+	}
+
+	if lost := counts["only_previous"]; thresholds.MaxLost >= 0 && lost > thresholds.MaxLost {
+		violations = append(violations, fmt.Sprintf("%d introductions were lost, but at most %d are allowed", lost, thresholds.MaxLost))
+	}
+
+	if changed := counts["different"]; thresholds.MaxChanged >= 0 && changed > thresholds.MaxChanged {
+		violations = append(violations, fmt.Sprintf("%d introductions changed, but at most %d are allowed", changed, thresholds.MaxChanged))
+	}
+
+	if gained := counts["improvement"]; thresholds.MaxGained >= 0 && gained > thresholds.MaxGained {
+		violations = append(violations, fmt.Sprintf("%d introductions were gained, but at most %d are allowed", gained, thresholds.MaxGained))
+	}
+
+	if len(pairs) > 0 && thresholds.MinOkayPercent > 0 {
+		okay := float64(counts["okay"]+counts["improvement"]) * 100 / float64(len(pairs))
+		if okay < thresholds.MinOkayPercent {
+			violations = append(violations, fmt.Sprintf("%0.2f%% of episodes are okay, but at least %0.2f%% are required", okay, thresholds.MinOkayPercent))
+		}
+	}
+
+	return violations
+}
+
+// Returns a description of every performance threshold exceeded by the new report. Resource usage is only compared if
+// it was recorded in both reports.
+func checkResourceThresholds(oldReport, newReport structs.Report, thresholds structs.ComparisonThresholds) []string {
+	var violations []string
+
+	check := func(name string, oldValue, newValue, limit float64, format func(float64) string) {
+		if limit < 0 || oldValue <= 0 {
+			return
+		}
+
+		if increase := (newValue - oldValue) * 100 / oldValue; increase > limit {
+			violations = append(violations, fmt.Sprintf("%s increased by %0.2f%% (from %s to %s), but at most %0.2f%% is allowed",
+				name, increase, format(oldValue), format(newValue), limit))
+		}
+	}
+
+	check("Analysis runtime", float64(oldReport.Runtime), float64(newReport.Runtime), thresholds.MaxRuntimeIncrease,
+		func(d float64) string { return time.Duration(d).Round(time.Second).String() })
+
+	if oldReport.Resources == nil || newReport.Resources == nil {
+		return violations
+	}
+
+	oldUsage, newUsage := oldReport.Resources, newReport.Resources
+
+	check("Average CPU usage", oldUsage.AverageCPUPercent, newUsage.AverageCPUPercent, thresholds.MaxCPUIncrease,
+		func(p float64) string { return fmt.Sprintf("%0.1f%%", p) })
+
+	check("Peak memory usage", float64(oldUsage.PeakMemoryBytes), float64(newUsage.PeakMemoryBytes),
+		thresholds.MaxMemoryIncrease, func(b float64) string { return formatBytes(uint64(b)) })
+
+	return violations
+}
+
+// Formats a number of bytes in mebibytes.
+func formatBytes(bytes uint64) string {
+	return fmt.Sprintf("%0.1f MiB", float64(bytes)/(1024*1024))
 }
 
 func unmarshalReport(path string) structs.Report {
@@ -128,12 +236,12 @@ func unmarshalReport(path string) structs.Report {
 	}
 
 	// Print report info
-	fmt.Printf("Report %s:\n", path)
-	fmt.Printf("Generated with Jellyfin %s running on %s\n", report.ServerInfo.Version, report.ServerInfo.OperatingSystem)
-	fmt.Printf("Analysis settings: %s\n", report.PluginConfig.AnalysisSettings())
-	fmt.Printf("Introduction reqs: %s\n", report.PluginConfig.IntroductionRequirements())
-	fmt.Printf("Episodes analyzed: %d\n", len(report.Intros))
-	fmt.Println()
+	logger.Printf("Report %s:\n", path)
+	logger.Printf("Generated with Jellyfin %s running on %s\n", report.ServerInfo.Version, report.ServerInfo.OperatingSystem)
+	logger.Printf("Analysis settings: %s\n", report.PluginConfig.AnalysisSettings())
+	logger.Printf("Introduction reqs: %s\n", report.PluginConfig.IntroductionRequirements())
+	logger.Printf("Episodes analyzed: %d\n", len(report.Intros))
+	logger.Println()
 
 	return report
 }
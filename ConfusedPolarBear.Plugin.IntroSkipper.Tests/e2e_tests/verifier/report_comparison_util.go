@@ -8,6 +8,9 @@ import (
 	"github.com/confusedpolarbear/intro_skipper_verifier/structs"
 )
 
+// Maximum number of seconds that timestamps can differ by before they are considered different.
+var comparisonTolerance = 5
+
 // report template helper functions
 
 // Sort show names alphabetically
@@ -39,7 +42,7 @@ func templateSortSeason(show structs.Seasons) []int {
 // Compare the episode with the provided ID in the old report to the episode in the new report.
 func templateCompareEpisodes(id string, reports structs.TemplateReportData) structs.IntroPair {
 	var pair structs.IntroPair
-	var tolerance int = 5
+	tolerance := comparisonTolerance
 
 	// Locate both episodes
 	pair.Old = reports.OldReport.IntroMap[id]
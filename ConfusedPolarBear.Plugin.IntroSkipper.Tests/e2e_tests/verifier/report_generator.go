@@ -33,34 +33,34 @@ func generateReport(hostAddress, apiKey, reportDestination string, keepTimestamp
 		panic(err)
 	}
 
-	fmt.Printf("Started at:  %s\n", start.Format(time.RFC1123))
-	fmt.Printf("Address:     %s\n", hostAddress)
-	fmt.Printf("Destination: %s\n", reportDestination)
-	fmt.Println()
+	logger.Printf("Started at:  %s\n", start.Format(time.RFC1123))
+	logger.Printf("Address:     %s\n", hostAddress)
+	logger.Printf("Destination: %s\n", reportDestination)
+	logger.Println()
 
 	// Get Jellyfin server information and plugin configuration
 	info := GetServerInfo(hostAddress, apiKey)
 	config := GetPluginConfiguration(hostAddress, apiKey)
-	fmt.Println()
+	logger.Println()
 
-	fmt.Printf("Jellyfin OS:       %s\n", info.OperatingSystem)
-	fmt.Printf("Jellyfin version:  %s\n", info.Version)
-	fmt.Printf("Analysis settings: %s\n", config.AnalysisSettings())
-	fmt.Printf("Introduction reqs: %s\n", config.IntroductionRequirements())
-	fmt.Printf("Erase timestamps:  %t\n", !keepTimestamps)
-	fmt.Println()
+	logger.Printf("Jellyfin OS:       %s\n", info.OperatingSystem)
+	logger.Printf("Jellyfin version:  %s\n", info.Version)
+	logger.Printf("Analysis settings: %s\n", config.AnalysisSettings())
+	logger.Printf("Introduction reqs: %s\n", config.IntroductionRequirements())
+	logger.Printf("Erase timestamps:  %t\n", !keepTimestamps)
+	logger.Println()
 
 	// If not keeping timestamps, run the fingerprint task.
 	// Otherwise, log that the task isn't being run
 	if !keepTimestamps {
 		runAnalysisAndWait(hostAddress, apiKey, pollInterval)
 	} else {
-		fmt.Println("[+] Using previously discovered intros")
+		logger.Infof("Using previously discovered intros")
 	}
-	fmt.Println()
+	logger.Println()
 
 	// Save all intros from the server
-	fmt.Println("[+] Saving intros")
+	logger.Infof("Saving intros")
 
 	var report structs.Report
 	rawIntros := SendRequest("GET", hostAddress+"/Intros/All", apiKey)
@@ -68,15 +68,44 @@ func generateReport(hostAddress, apiKey, reportDestination string, keepTimestamp
 		panic(err)
 	}
 
-	// Calculate the durations of all intros
-	for i := range report.Intros {
-		intro := report.Intros[i]
-		intro.Duration = intro.IntroEnd - intro.IntroStart
-		report.Intros[i] = intro
+	logger.Infof("Saving credits")
+
+	rawCredits := SendRequest("GET", hostAddress+"/Intros/All?mode=Credits", apiKey)
+	if err := json.Unmarshal(rawCredits, &report.Credits); err != nil {
+		panic(err)
+	}
+
+	// Lookup the runtime and media details of every episode with a segment
+	var ids []string
+	seen := make(map[string]bool)
+	for _, segments := range [][]structs.Intro{report.Intros, report.Credits} {
+		for _, segment := range segments {
+			if id := normalizeId(segment.EpisodeId); !seen[id] {
+				seen[id] = true
+				ids = append(ids, segment.EpisodeId)
+			}
+		}
+	}
+
+	media := GetMediaDetails(hostAddress, apiKey, ids)
+
+	// Calculate the durations of all intros and credits and store the media details of each episode
+	for _, segments := range [][]structs.Intro{report.Intros, report.Credits} {
+		for i := range segments {
+			segment := segments[i]
+			segment.Duration = segment.IntroEnd - segment.IntroStart
+
+			if details, ok := media[normalizeId(segment.EpisodeId)]; ok {
+				segment.Media = &details
+				segment.EpisodeDuration = details.Duration()
+			}
+
+			segments[i] = segment
+		}
 	}
 
-	fmt.Println()
-	fmt.Println("[+] Saving report")
+	logger.Println()
+	logger.Infof("Saving report")
 
 	// Store timing data, server information, and plugin configuration
 	report.StartedAt = start
@@ -97,8 +126,13 @@ func generateReport(hostAddress, apiKey, reportDestination string, keepTimestamp
 
 	// Change report permissions
 	exec.Command("chown", "1000:1000", reportDestination).Run()
+	logger.Println()
+
+	// Save a diagnostics archive next to the report
+	collectDiagnostics(hostAddress, apiKey, diagnosticsPath(reportDestination), marshalled)
+	logger.Println()
 
-	fmt.Println("[+] Done")
+	logger.Infof("Done")
 }
 
 func runAnalysisAndWait(hostAddress, apiKey string, pollInterval time.Duration) {
@@ -109,19 +143,17 @@ func runAnalysisAndWait(hostAddress, apiKey string, pollInterval time.Duration)
 		CurrentProgressPercentage int
 	}
 
-	fmt.Println("[+] Erasing previously discovered intros")
+	logger.Infof("Erasing previously discovered intros")
 	SendRequest("POST", hostAddress+"/Intros/EraseTimestamps", apiKey)
-	fmt.Println()
 
 	var taskIds = []string{
 		"f64d8ad58e3d7b98548e1a07697eb100", // v0.1.8
 		"8863329048cc357f7dfebf080f2fe204",
 		"6adda26c5261c40e8fa4a7e7df568be2"}
 
-	fmt.Println("[+] Starting analysis task")
+	logger.Infof("Starting analysis task")
 	for _, id := range taskIds {
 		body := SendRequest("POST", hostAddress+"/ScheduledTasks/Running/"+id, apiKey)
-		fmt.Println()
 
 		// If the scheduled task was found, store the task ID for later
 		if !strings.Contains(string(body), "Not Found") {
@@ -134,25 +166,37 @@ func runAnalysisAndWait(hostAddress, apiKey string, pollInterval time.Duration)
 		panic("unable to find scheduled task")
 	}
 
-	fmt.Println("[+] Waiting for analysis task to complete")
-	fmt.Print("[+] Episodes analyzed: 0%")
+	logger.Infof("Waiting for analysis task to complete")
 
 	var info taskInfo       // Last known scheduled task state
 	var lastQuery time.Time // Time the task info was last updated
+	reported := -1          // Progress that was last logged when not on a terminal
+
+	if interactive {
+		fmt.Fprint(logOutput, "[+] Episodes analyzed: 0%")
+	}
 
 	for {
 		time.Sleep(500 * time.Millisecond)
 
-		// Update the spinner
-		if spinnerIndex++; spinnerIndex >= len(spinners) {
-			spinnerIndex = 0
+		if interactive {
+			// Update the spinner
+			if spinnerIndex++; spinnerIndex >= len(spinners) {
+				spinnerIndex = 0
+			}
+
+			fmt.Fprintf(logOutput, "\r[%s] Episodes analyzed: %d%%", spinners[spinnerIndex], info.CurrentProgressPercentage)
+		} else if info.CurrentProgressPercentage != reported {
+			// Redrawing a spinner would garble logs, so only log changes in progress
+			reported = info.CurrentProgressPercentage
+			logger.Infof("Episodes analyzed: %d%%", reported)
 		}
 
-		fmt.Printf("\r[%s] Episodes analyzed: %d%%", spinners[spinnerIndex], info.CurrentProgressPercentage)
-
 		if info.CurrentProgressPercentage == 100 {
-			fmt.Printf("\r[+]") // reset the spinner
-			fmt.Println()
+			if interactive {
+				fmt.Fprintln(logOutput, "\r[+]") // reset the spinner
+			}
+
 			break
 		}
 
@@ -166,8 +210,8 @@ func runAnalysisAndWait(hostAddress, apiKey string, pollInterval time.Duration)
 		raw := SendRequest("GET", hostAddress+"/ScheduledTasks/"+taskId+"?hideUrl=1", apiKey)
 
 		if err := json.Unmarshal(raw, &info); err != nil {
-			fmt.Printf("[!] Unable to unmarshal response into taskInfo struct: %s\n", err)
-			fmt.Printf("%s\n", raw)
+			logger.Warnf("Unable to unmarshal response into taskInfo struct: %s", err)
+			logger.Printf("%s\n", raw)
 			continue
 		}
 
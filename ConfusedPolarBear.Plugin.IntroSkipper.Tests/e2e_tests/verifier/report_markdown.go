@@ -0,0 +1,320 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/confusedpolarbear/intro_skipper_verifier/structs"
+)
+
+// Maximum number of shows to list in the regressed shows table.
+const maxRegressedShows = 10
+
+// All warning categories that templateCompareEpisodes can return, in the order they are summarized.
+var warningCategories = []struct {
+	Short       string
+	Description string
+}{
+	{"okay", "Okay"},
+	{"improvement", "Gains"},
+	{"different", "Changed"},
+	{"only_previous", "Losses"},
+	{"missing", "Never found"},
+}
+
+// Regression counters for a group of episodes (a show or an audio codec).
+type showRegressions struct {
+	Name    string
+	Total   int
+	Changed int
+	Lost    int
+}
+
+// Compare every episode found in either report.
+func compareAllEpisodes(reports structs.TemplateReportData) []structs.IntroPair {
+	var ids []string
+	seen := make(map[string]bool)
+
+	for _, report := range []structs.Report{reports.OldReport, reports.NewReport} {
+		for _, intro := range report.Intros {
+			if seen[intro.EpisodeId] {
+				continue
+			}
+
+			seen[intro.EpisodeId] = true
+			ids = append(ids, intro.EpisodeId)
+		}
+	}
+
+	var pairs []structs.IntroPair
+	for _, id := range ids {
+		pairs = append(pairs, templateCompareEpisodes(id, reports))
+	}
+
+	return pairs
+}
+
+// Writes a compact Markdown summary of the differences between two reports, suitable for posting in a pull request.
+func writeMarkdownSummary(w io.Writer, reports structs.TemplateReportData) error {
+	var md strings.Builder
+	oldReport, newReport := reports.OldReport, reports.NewReport
+	pairs := compareAllEpisodes(reports)
+
+	md.WriteString("## Intro Timestamp Differential\n\n")
+
+	md.WriteString("| | First report | Second report |\n")
+	md.WriteString("| --- | --- | --- |\n")
+	fmt.Fprintf(&md, "| Path | `%s` | `%s` |\n", oldReport.Path, newReport.Path)
+	fmt.Fprintf(&md, "| Jellyfin | %s on %s | %s on %s |\n",
+		oldReport.ServerInfo.Version, oldReport.ServerInfo.OperatingSystem,
+		newReport.ServerInfo.Version, newReport.ServerInfo.OperatingSystem)
+	fmt.Fprintf(&md, "| Episodes | %d | %d |\n", len(oldReport.Intros), len(newReport.Intros))
+	md.WriteString("\n")
+
+	// Totals per warning category
+	counts := make(map[string]int)
+	for _, pair := range pairs {
+		counts[pair.WarningShort]++
+	}
+
+	md.WriteString("### Totals\n\n")
+	md.WriteString("| Category | Episodes | Percent |\n")
+	md.WriteString("| --- | ---: | ---: |\n")
+	for _, category := range warningCategories {
+		fmt.Fprintf(&md, "| %s (`%s`) | %d | %s |\n",
+			category.Description,
+			category.Short,
+			counts[category.Short],
+			formatPercent(counts[category.Short], len(pairs)))
+	}
+	fmt.Fprintf(&md, "| **Total** | **%d** | |\n\n", len(pairs))
+
+	// Shows with the most lost or changed introductions
+	md.WriteString("### Top regressed shows\n\n")
+	if regressed := regressedShows(pairs); len(regressed) == 0 {
+		md.WriteString("No regressions.\n\n")
+	} else {
+		md.WriteString("| Show | Losses | Changed | Episodes |\n")
+		md.WriteString("| --- | ---: | ---: | ---: |\n")
+		for _, show := range regressed {
+			fmt.Fprintf(&md, "| %s | %d | %d | %d |\n", escapeMarkdown(show.Name), show.Lost, show.Changed, show.Total)
+		}
+		md.WriteString("\n")
+	}
+
+	// Regressions grouped by audio codec, if the reports recorded media details
+	if codecs := regressionsByCodec(pairs); len(codecs) > 0 {
+		md.WriteString("### Regressions by audio codec\n\n")
+		md.WriteString("| Codec | Losses | Changed | Episodes |\n")
+		md.WriteString("| --- | ---: | ---: | ---: |\n")
+		for _, codec := range codecs {
+			fmt.Fprintf(&md, "| %s | %d | %d | %d |\n", escapeMarkdown(codec.Name), codec.Lost, codec.Changed, codec.Total)
+		}
+		md.WriteString("\n")
+	}
+
+	// Plugin settings which differ between the two reports
+	md.WriteString("### Settings diff\n\n")
+	if diff := diffPluginConfigurations(oldReport.PluginConfig, newReport.PluginConfig); len(diff) == 0 {
+		md.WriteString("No settings changed.\n\n")
+	} else {
+		md.WriteString("| Setting | First report | Second report |\n")
+		md.WriteString("| --- | --- | --- |\n")
+		for _, d := range diff {
+			fmt.Fprintf(&md, "| %s | `%s` | `%s` |\n", d[0], d[1], d[2])
+		}
+		md.WriteString("\n")
+	}
+
+	// Analysis runtime
+	md.WriteString("### Runtime\n\n")
+	md.WriteString("| First report | Second report | Delta |\n")
+	md.WriteString("| ---: | ---: | ---: |\n")
+	fmt.Fprintf(&md, "| %s | %s | %s |\n",
+		oldReport.Runtime.Round(time.Second),
+		newReport.Runtime.Round(time.Second),
+		formatDurationDelta(oldReport.Runtime, newReport.Runtime))
+
+	// Container resource usage, if recorded by the e2e wrapper
+	if oldReport.Resources != nil || newReport.Resources != nil {
+		md.WriteString("\n### Resource usage\n\n")
+		md.WriteString("| Metric | First report | Second report |\n")
+		md.WriteString("| --- | ---: | ---: |\n")
+		for _, row := range resourceUsageRows(oldReport.Resources, newReport.Resources) {
+			fmt.Fprintf(&md, "| %s | %s | %s |\n", row[0], row[1], row[2])
+		}
+	}
+
+	_, err := io.WriteString(w, md.String())
+	return err
+}
+
+// Returns the shows with the most regressed episodes, worst first.
+func regressedShows(pairs []structs.IntroPair) []showRegressions {
+	regressed := groupRegressions(pairs, func(pair structs.IntroPair) string {
+		if pair.Old.Series != "" {
+			return pair.Old.Series
+		}
+
+		return pair.New.Series
+	})
+
+	if len(regressed) > maxRegressedShows {
+		regressed = regressed[:maxRegressedShows]
+	}
+
+	return regressed
+}
+
+// Returns the number of regressed episodes per audio codec, worst first.
+// Episodes without recorded media details are not included.
+func regressionsByCodec(pairs []structs.IntroPair) []showRegressions {
+	var withMedia []structs.IntroPair
+	for _, pair := range pairs {
+		if pair.New.Media != nil || pair.Old.Media != nil {
+			withMedia = append(withMedia, pair)
+		}
+	}
+
+	return groupRegressions(withMedia, func(pair structs.IntroPair) string {
+		media := pair.New.Media
+		if media == nil {
+			media = pair.Old.Media
+		}
+
+		if media.AudioCodec == "" {
+			return "unknown"
+		}
+
+		return fmt.Sprintf("%s (%d channels)", media.AudioCodec, media.AudioChannels)
+	})
+}
+
+// Counts the regressed episodes in each group and returns all groups with at least one regression, worst first.
+func groupRegressions(pairs []structs.IntroPair, key func(structs.IntroPair) string) []showRegressions {
+	groups := make(map[string]*showRegressions)
+
+	for _, pair := range pairs {
+		name := key(pair)
+
+		group, ok := groups[name]
+		if !ok {
+			group = &showRegressions{Name: name}
+			groups[name] = group
+		}
+
+		group.Total++
+
+		switch pair.WarningShort {
+		case "different":
+			group.Changed++
+		case "only_previous":
+			group.Lost++
+		}
+	}
+
+	var regressed []showRegressions
+	for _, group := range groups {
+		if group.Changed+group.Lost > 0 {
+			regressed = append(regressed, *group)
+		}
+	}
+
+	// Sort by number of lost intros, then changed intros, then name
+	sort.Slice(regressed, func(i, j int) bool {
+		a, b := regressed[i], regressed[j]
+		if a.Lost != b.Lost {
+			return a.Lost > b.Lost
+		} else if a.Changed != b.Changed {
+			return a.Changed > b.Changed
+		}
+
+		return a.Name < b.Name
+	})
+
+	return regressed
+}
+
+// Returns the name, old value and new value of every plugin setting which differs between two configurations.
+func diffPluginConfigurations(oldConfig, newConfig structs.PluginConfiguration) [][3]string {
+	var diff [][3]string
+
+	oldValue, newValue := reflect.ValueOf(oldConfig), reflect.ValueOf(newConfig)
+	for i := 0; i < oldValue.NumField(); i++ {
+		oldField, newField := oldValue.Field(i).Interface(), newValue.Field(i).Interface()
+		if reflect.DeepEqual(oldField, newField) {
+			continue
+		}
+
+		diff = append(diff, [3]string{
+			oldValue.Type().Field(i).Name,
+			fmt.Sprint(oldField),
+			fmt.Sprint(newField),
+		})
+	}
+
+	return diff
+}
+
+// Formats part as a percentage of whole.
+func formatPercent(part, whole int) string {
+	if whole == 0 {
+		return "0%"
+	}
+
+	return fmt.Sprintf("%.2f%%", float64(part*100)/float64(whole))
+}
+
+// Formats the change from the old duration to the new duration, i.e. "+1m30s (+12.50%)".
+func formatDurationDelta(oldDuration, newDuration time.Duration) string {
+	delta := (newDuration - oldDuration).Round(time.Second)
+
+	sign := ""
+	if delta >= 0 {
+		sign = "+"
+	}
+
+	if oldDuration == 0 {
+		return sign + delta.String()
+	}
+
+	percent := float64(newDuration-oldDuration) * 100 / float64(oldDuration)
+	return fmt.Sprintf("%s%s (%s%.2f%%)", sign, delta, sign, percent)
+}
+
+// Returns the name and formatted value in both reports of every resource usage metric. Reports without recorded
+// resource usage are shown as "-".
+func resourceUsageRows(oldUsage, newUsage *structs.ResourceUsage) [][3]string {
+	metrics := []struct {
+		name  string
+		value func(*structs.ResourceUsage) string
+	}{
+		{"Peak CPU", func(u *structs.ResourceUsage) string { return fmt.Sprintf("%0.1f%%", u.PeakCPUPercent) }},
+		{"Average CPU", func(u *structs.ResourceUsage) string { return fmt.Sprintf("%0.1f%%", u.AverageCPUPercent) }},
+		{"Peak memory", func(u *structs.ResourceUsage) string { return formatBytes(u.PeakMemoryBytes) }},
+		{"Average memory", func(u *structs.ResourceUsage) string { return formatBytes(u.AverageMemoryBytes) }},
+	}
+
+	var rows [][3]string
+	for _, metric := range metrics {
+		row := [3]string{metric.name, "-", "-"}
+		for i, usage := range []*structs.ResourceUsage{oldUsage, newUsage} {
+			if usage != nil {
+				row[i+1] = metric.value(usage)
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+// Escapes characters which would break a Markdown table cell.
+func escapeMarkdown(raw string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(raw)
+}
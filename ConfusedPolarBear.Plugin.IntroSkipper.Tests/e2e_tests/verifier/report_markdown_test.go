@@ -0,0 +1,121 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/confusedpolarbear/intro_skipper_verifier/structs"
+)
+
+func TestDiffPluginConfigurations(t *testing.T) {
+	oldConfig := structs.PluginConfiguration{MaxParallelism: 2, AnalysisPercent: 25}
+	newConfig := structs.PluginConfiguration{MaxParallelism: 4, AnalysisPercent: 25}
+
+	diff := diffPluginConfigurations(oldConfig, newConfig)
+	if len(diff) != 1 {
+		t.Fatalf("expected one changed setting, found %v", diff)
+	}
+
+	if expected := [3]string{"MaxParallelism", "2", "4"}; diff[0] != expected {
+		t.Errorf("setting was diffed incorrectly: %v", diff[0])
+	}
+}
+
+func TestRegressedShows(t *testing.T) {
+	pairs := []structs.IntroPair{
+		{Old: structs.Intro{Series: "A"}, WarningShort: "different"},
+		{Old: structs.Intro{Series: "B"}, WarningShort: "only_previous"},
+		{Old: structs.Intro{Series: "B"}, WarningShort: "okay"},
+		{New: structs.Intro{Series: "C"}, WarningShort: "improvement"},
+	}
+
+	shows := regressedShows(pairs)
+	if len(shows) != 2 {
+		t.Fatalf("expected two regressed shows, found %v", shows)
+	}
+
+	if shows[0].Name != "B" || shows[0].Lost != 1 || shows[0].Total != 2 {
+		t.Errorf("show with lost intros should be listed first: %v", shows[0])
+	}
+
+	if shows[1].Name != "A" || shows[1].Changed != 1 {
+		t.Errorf("show with changed intros should be listed second: %v", shows[1])
+	}
+}
+
+func TestFormatDurationDelta(t *testing.T) {
+	if actual := formatDurationDelta(2*time.Minute, 3*time.Minute); actual != "+1m0s (+50.00%)" {
+		t.Errorf("slower runtime was formatted incorrectly: %s", actual)
+	}
+
+	if actual := formatDurationDelta(2*time.Minute, time.Minute); actual != "-1m0s (-50.00%)" {
+		t.Errorf("faster runtime was formatted incorrectly: %s", actual)
+	}
+}
+
+func TestCheckThresholds(t *testing.T) {
+	pairs := []structs.IntroPair{
+		{WarningShort: "okay"},
+		{WarningShort: "improvement"},
+		{WarningShort: "different"},
+		{WarningShort: "only_previous"},
+	}
+
+	unlimited := structs.ComparisonThresholds{MaxLost: -1, MaxChanged: -1, MaxGained: -1}
+	if violations := checkThresholds(pairs, unlimited); len(violations) != 0 {
+		t.Errorf("disabled thresholds should never be exceeded: %v", violations)
+	}
+
+	strict := structs.ComparisonThresholds{MaxLost: 0, MaxChanged: 0, MaxGained: 0, MinOkayPercent: 75}
+	if violations := checkThresholds(pairs, strict); len(violations) != 4 {
+		t.Errorf("expected four exceeded thresholds, found %v", violations)
+	}
+
+	lenient := structs.ComparisonThresholds{MaxLost: 1, MaxChanged: 1, MaxGained: 1, MinOkayPercent: 50}
+	if violations := checkThresholds(pairs, lenient); len(violations) != 0 {
+		t.Errorf("thresholds should be inclusive: %v", violations)
+	}
+}
+
+func TestCheckResourceThresholds(t *testing.T) {
+	oldReport := structs.Report{
+		Runtime:   time.Minute,
+		Resources: &structs.ResourceUsage{AverageCPUPercent: 100, PeakMemoryBytes: 512 << 20},
+	}
+
+	newReport := structs.Report{
+		Runtime:   90 * time.Second,
+		Resources: &structs.ResourceUsage{AverageCPUPercent: 110, PeakMemoryBytes: 1024 << 20},
+	}
+
+	unlimited := structs.ComparisonThresholds{MaxRuntimeIncrease: -1, MaxCPUIncrease: -1, MaxMemoryIncrease: -1}
+	if violations := checkResourceThresholds(oldReport, newReport, unlimited); len(violations) != 0 {
+		t.Errorf("disabled thresholds should never be exceeded: %v", violations)
+	}
+
+	strict := structs.ComparisonThresholds{MaxRuntimeIncrease: 25, MaxCPUIncrease: 10, MaxMemoryIncrease: 50}
+	violations := checkResourceThresholds(oldReport, newReport, strict)
+	if len(violations) != 2 {
+		t.Fatalf("expected two exceeded thresholds, found %v", violations)
+	}
+
+	expected := "Peak memory usage increased by 100.00% (from 512.0 MiB to 1024.0 MiB), but at most 50.00% is allowed"
+	if violations[1] != expected {
+		t.Errorf("incorrect violation: %s", violations[1])
+	}
+
+	// Resource usage can only be compared if it was recorded in both reports
+	newReport.Resources = nil
+	if violations := checkResourceThresholds(oldReport, newReport, strict); len(violations) != 1 {
+		t.Errorf("expected only the runtime threshold to be checked, found %v", violations)
+	}
+}
+
+func TestResourceUsageRows(t *testing.T) {
+	usage := &structs.ResourceUsage{PeakCPUPercent: 250.25, PeakMemoryBytes: 3 << 19}
+
+	rows := resourceUsageRows(nil, usage)
+	if rows[0] != [3]string{"Peak CPU", "-", "250.2%"} || rows[2] != [3]string{"Peak memory", "-", "1.5 MiB"} {
+		t.Errorf("incorrect rows: %v", rows)
+	}
+}
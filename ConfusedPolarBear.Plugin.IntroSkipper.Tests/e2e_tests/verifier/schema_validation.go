@@ -16,33 +16,33 @@ func validateApiSchema(hostAddress, apiKey, rawIds string) {
 
 	start := time.Now()
 
-	fmt.Printf("Started at:  %s\n", start.Format(time.RFC1123))
-	fmt.Printf("Address:     %s\n", hostAddress)
-	fmt.Println()
+	logger.Printf("Started at:  %s\n", start.Format(time.RFC1123))
+	logger.Printf("Address:     %s\n", hostAddress)
+	logger.Println()
 
 	// Get Jellyfin server information
 	info := GetServerInfo(hostAddress, apiKey)
-	fmt.Println()
+	logger.Println()
 
-	fmt.Printf("Jellyfin OS:      %s\n", info.OperatingSystem)
-	fmt.Printf("Jellyfin version: %s\n", info.Version)
-	fmt.Println()
+	logger.Printf("Jellyfin OS:      %s\n", info.OperatingSystem)
+	logger.Printf("Jellyfin version: %s\n", info.Version)
+	logger.Println()
 
 	for _, id := range ids {
-		fmt.Printf("[+] Validating item %s\n", id)
+		logger.Infof("Validating item %s", id)
 
-		fmt.Println("  [+] Validating API v1 (implicitly versioned)")
+		logger.Infof("Validating API v1 (implicitly versioned)")
 		intro, schema := getTimestampsV1(hostAddress, apiKey, id, "")
 		validateV1Intro(id, intro, schema)
 
-		fmt.Println("  [+] Validating API v1 (explicitly versioned)")
+		logger.Infof("Validating API v1 (explicitly versioned)")
 		intro, schema = getTimestampsV1(hostAddress, apiKey, id, "v1")
 		validateV1Intro(id, intro, schema)
 
-		fmt.Println()
+		logger.Println()
 	}
 
-	fmt.Printf("Validated %d items in %s\n", len(ids), time.Since(start).Round(time.Millisecond))
+	logger.Printf("Validated %d items in %s\n", len(ids), time.Since(start).Round(time.Millisecond))
 }
 
 // Validates the returned intro object, panicking on any error.
@@ -7,6 +7,12 @@ type Intro struct {
 	Season int
 	Title  string
 
+	// Length of the episode in seconds. Zero if unknown.
+	EpisodeDuration float32 `json:",omitempty"`
+
+	// Details about the episode's media file. Only present in reports generated with media lookups.
+	Media *MediaDetails `json:",omitempty"`
+
 	IntroStart float32
 	IntroEnd   float32
 	Duration   float32
@@ -0,0 +1,27 @@
+package structs
+
+// Details about the media file of an episode.
+type MediaDetails struct {
+	// Length of the episode in ticks (100 nanosecond intervals).
+	RunTimeTicks int64
+
+	Path      string
+	Container string
+
+	// Codec and channel count of the default audio stream.
+	AudioCodec    string
+	AudioChannels int
+
+	Chapters []Chapter
+}
+
+// A chapter marker in an episode.
+type Chapter struct {
+	Name               string
+	StartPositionTicks int64
+}
+
+// Returns the length of the episode in seconds.
+func (m MediaDetails) Duration() float32 {
+	return float32(float64(m.RunTimeTicks) / 10_000_000)
+}
@@ -13,6 +13,9 @@ type PluginConfiguration struct {
 	AnalysisPercent      int
 	AnalysisLengthLimit  int
 	MinimumIntroDuration int
+	MaximumIntroDuration int
+
+	MaximumEpisodeCreditsDuration int
 }
 
 func (c PluginConfiguration) AnalysisSettings() string {
@@ -14,7 +14,11 @@ type Report struct {
 	ServerInfo   PublicInfo
 	PluginConfig PluginConfiguration
 
-	Intros []Intro
+	Intros  []Intro
+	Credits []Intro
+
+	// Resource usage of the server's container during analysis. Only recorded by the e2e wrapper for local servers.
+	Resources *ResourceUsage `json:",omitempty"`
 
 	// Intro lookup table. Only populated when loading a report.
 	IntroMap map[string]Intro `json:"-"`
@@ -46,3 +50,23 @@ type IntroPair struct {
 	// If this pair of intros is not okay, a short description about the cause
 	Warning string
 }
+
+// Limits on how much a new report may regress compared to an old report. Negative limits are not enforced.
+type ComparisonThresholds struct {
+	// Maximum number of episodes with an introduction in the old report but not the new one.
+	MaxLost int
+
+	// Maximum number of episodes with different timestamps in both reports.
+	MaxChanged int
+
+	// Maximum number of episodes with an introduction in the new report but not the old one.
+	MaxGained int
+
+	// Minimum percentage of episodes that must be okay or improved.
+	MinOkayPercent float64
+
+	// Maximum percentage that the analysis runtime, average CPU usage and peak memory usage may increase by.
+	MaxRuntimeIncrease float64
+	MaxCPUIncrease     float64
+	MaxMemoryIncrease  float64
+}
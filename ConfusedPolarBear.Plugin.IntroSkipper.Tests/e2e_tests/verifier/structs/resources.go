@@ -0,0 +1,29 @@
+package structs
+
+import "time"
+
+// Resource usage of a server's container at a single point during analysis.
+type ResourceSample struct {
+	// Time since sampling started.
+	Elapsed time.Duration
+
+	// CPU usage as a percentage of a single core. Can exceed 100% on hosts with multiple cores.
+	CPUPercent float64
+
+	// Memory used by the container.
+	MemoryBytes uint64
+
+	// Progress of the running analysis task, as a percentage.
+	Progress float64
+}
+
+// Resource usage of a server's container sampled during analysis.
+type ResourceUsage struct {
+	Samples []ResourceSample
+
+	PeakCPUPercent    float64
+	AverageCPUPercent float64
+
+	PeakMemoryBytes    uint64
+	AverageMemoryBytes uint64
+}
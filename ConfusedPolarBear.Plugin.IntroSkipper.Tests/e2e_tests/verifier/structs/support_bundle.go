@@ -0,0 +1,22 @@
+package structs
+
+// Parsed contents of the plugin's /IntroSkipper/SupportBundle endpoint.
+type SupportBundle struct {
+	JellyfinVersion string
+
+	// Plugin version (i.e. 0.1.8) and the abbreviated commit it was built from, if known.
+	PluginVersion string
+	PluginCommit  string
+
+	QueuedEpisodes int
+	QueuedSeasons  int
+
+	// Names of all warnings set by the plugin. Empty if no warnings were set.
+	Warnings []string
+
+	// FFmpeg compatibility status. "okay" if all checks passed.
+	FFmpegError string
+
+	// FFmpeg logs captured during compatibility checks, keyed by check name.
+	ChromaprintLogs map[string]string
+}
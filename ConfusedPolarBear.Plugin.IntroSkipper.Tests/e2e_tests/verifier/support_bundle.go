@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/confusedpolarbear/intro_skipper_verifier/structs"
+)
+
+// Parses the Markdown formatted support bundle returned by the plugin.
+func parseSupportBundle(raw string) (structs.SupportBundle, error) {
+	bundle := structs.SupportBundle{
+		ChromaprintLogs: make(map[string]string),
+	}
+
+	// Name of the FFmpeg log currently being read and the lines read so far
+	var logName string
+	var logLines []string
+	inLog := false
+
+	scanner := bufio.NewScanner(strings.NewReader(raw))
+	scanner.Buffer(nil, 1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// Collect FFmpeg log lines until the closing triple backtick
+		if inLog {
+			if line == "```" {
+				bundle.ChromaprintLogs[logName] = strings.Join(logLines, "\n")
+				inLog, logLines = false, nil
+			} else {
+				logLines = append(logLines, line)
+			}
+
+			continue
+		}
+
+		// Format: "FFmpeg NAME:" followed by a fenced code block
+		if strings.HasPrefix(line, "FFmpeg ") && strings.HasSuffix(line, ":") {
+			logName = strings.TrimSuffix(strings.TrimPrefix(line, "FFmpeg "), ":")
+			continue
+		}
+
+		if line == "```" && logName != "" {
+			inLog = true
+			continue
+		}
+
+		// Format: "* Key: value"
+		if !strings.HasPrefix(line, "* ") {
+			continue
+		}
+
+		parts := strings.SplitN(strings.TrimPrefix(line, "* "), ": ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		key, value := parts[0], parts[1]
+
+		switch key {
+		case "Jellyfin version":
+			bundle.JellyfinVersion = value
+
+		case "Plugin version":
+			version := strings.SplitN(value, "+", 2)
+			bundle.PluginVersion = version[0]
+			if len(version) == 2 {
+				bundle.PluginCommit = version[1]
+			}
+
+		case "Queue contents":
+			// Format: "N episodes, M seasons"
+			if _, err := fmt.Sscanf(value, "%d episodes, %d seasons", &bundle.QueuedEpisodes, &bundle.QueuedSeasons); err != nil {
+				return bundle, fmt.Errorf("unable to parse queue contents %q: %w", value, err)
+			}
+
+		case "Warnings":
+			warnings := strings.Trim(value, "`")
+			if warnings == "None" || warnings == "" {
+				continue
+			}
+
+			for _, warning := range strings.Split(warnings, ",") {
+				bundle.Warnings = append(bundle.Warnings, strings.TrimSpace(warning))
+			}
+
+		case "FFmpeg":
+			bundle.FFmpegError = strings.Trim(value, "`")
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return bundle, err
+	}
+
+	if inLog {
+		return bundle, fmt.Errorf("FFmpeg %s log is missing a closing code fence", strconv.Quote(logName))
+	}
+
+	if bundle.PluginVersion == "" {
+		return bundle, fmt.Errorf("support bundle is missing the plugin version")
+	}
+
+	return bundle, nil
+}
+
+// FFmpeg compatibility errors which prevent the plugin from analyzing any episodes.
+var fatalFFmpegErrors = []string{"chromaprint_not_supported", "silencedetect_not_supported"}
+
+// Checks the support bundle for any problems. If expectedVersion is not empty, the plugin version
+// in the bundle must match it. Returns a description of every problem found.
+func checkSupportBundle(bundle structs.SupportBundle, expectedVersion string) []string {
+	var problems []string
+
+	if len(bundle.Warnings) > 0 {
+		problems = append(problems, fmt.Sprintf("plugin reported warnings: %s", strings.Join(bundle.Warnings, ", ")))
+	}
+
+	for _, fatal := range fatalFFmpegErrors {
+		if bundle.FFmpegError == fatal {
+			problems = append(problems, fmt.Sprintf("FFmpeg is incompatible with the plugin: %s", fatal))
+		}
+	}
+
+	if expectedVersion != "" && normalizeVersion(bundle.PluginVersion) != normalizeVersion(expectedVersion) {
+		problems = append(problems, fmt.Sprintf(
+			"plugin version %s does not match the installed version %s",
+			bundle.PluginVersion,
+			expectedVersion))
+	}
+
+	return problems
+}
+
+// Normalizes a version string to its first three components, i.e. "0.1.8.0" becomes "0.1.8".
+func normalizeVersion(version string) string {
+	parts := strings.Split(strings.TrimPrefix(version, "v"), ".")
+	for len(parts) < 3 {
+		parts = append(parts, "0")
+	}
+
+	return strings.Join(parts[:3], ".")
+}
+
+// Downloads, parses and checks the support bundle from the provided server, exiting with an error if any
+// problems are found.
+func verifySupportBundle(hostAddress, apiKey, expectedVersion string) {
+	logger.Infof("Checking support bundle")
+
+	raw := SendRequest("GET", hostAddress+"/IntroSkipper/SupportBundle", apiKey)
+	bundle, err := parseSupportBundle(string(raw))
+	if err != nil {
+		panic(err)
+	}
+
+	logger.Printf("Jellyfin version: %s\n", bundle.JellyfinVersion)
+	logger.Printf("Plugin version:   %s (commit %s)\n", bundle.PluginVersion, bundle.PluginCommit)
+	logger.Printf("Queue contents:   %d episodes, %d seasons\n", bundle.QueuedEpisodes, bundle.QueuedSeasons)
+	logger.Printf("FFmpeg status:    %s\n", bundle.FFmpegError)
+	logger.Println()
+
+	problems := checkSupportBundle(bundle, expectedVersion)
+	for _, problem := range problems {
+		logger.Errorf("%s", problem)
+	}
+
+	if len(problems) > 0 {
+		os.Exit(1)
+	}
+
+	logger.Infof("Support bundle is okay")
+}
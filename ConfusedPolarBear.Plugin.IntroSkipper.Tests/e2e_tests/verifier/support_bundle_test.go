@@ -0,0 +1,119 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/confusedpolarbear/intro_skipper_verifier/structs"
+)
+
+const okayBundle = "* Jellyfin version: 10.8.9\n" +
+	"* Plugin version: 0.1.8+0123456789ab\n" +
+	"* Queue contents: 24 episodes, 2 seasons\n" +
+	"* Warnings: `None`\n" +
+	"* FFmpeg: `okay`\n\n" +
+	"FFmpeg version:\n```\nffmpeg version 5.1.2-Jellyfin\nbuilt with gcc\n```\n\n"
+
+const brokenBundle = "* Jellyfin version: 10.8.4\n" +
+	"* Plugin version: 0.1.7\n" +
+	"* Queue contents: 0 episodes, 0 seasons\n" +
+	"* Warnings: `UnableToAddSkipButton, IncompatibleFFmpegBuild`\n" +
+	"* FFmpeg: `chromaprint_not_supported`\n\n" +
+	"FFmpeg version:\n```\nffmpeg version 4.4\n```\n\n" +
+	"FFmpeg chromaprint:\n```\nUnknown format chromaprint\n```\n\n"
+
+func TestParseSupportBundle(t *testing.T) {
+	bundle, err := parseSupportBundle(okayBundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bundle.JellyfinVersion != "10.8.9" {
+		t.Errorf("incorrect Jellyfin version %s", bundle.JellyfinVersion)
+	}
+
+	if bundle.PluginVersion != "0.1.8" || bundle.PluginCommit != "0123456789ab" {
+		t.Errorf("incorrect plugin version %s or commit %s", bundle.PluginVersion, bundle.PluginCommit)
+	}
+
+	if bundle.QueuedEpisodes != 24 || bundle.QueuedSeasons != 2 {
+		t.Errorf("incorrect queue contents %d, %d", bundle.QueuedEpisodes, bundle.QueuedSeasons)
+	}
+
+	if len(bundle.Warnings) != 0 {
+		t.Errorf("expected no warnings, found %v", bundle.Warnings)
+	}
+
+	if bundle.FFmpegError != "okay" {
+		t.Errorf("incorrect FFmpeg status %s", bundle.FFmpegError)
+	}
+
+	if log := bundle.ChromaprintLogs["version"]; log != "ffmpeg version 5.1.2-Jellyfin\nbuilt with gcc" {
+		t.Errorf("incorrect FFmpeg version log %q", log)
+	}
+}
+
+func TestParseSupportBundleWithWarnings(t *testing.T) {
+	bundle, err := parseSupportBundle(brokenBundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bundle.PluginCommit != "" {
+		t.Errorf("expected no commit, found %s", bundle.PluginCommit)
+	}
+
+	if len(bundle.Warnings) != 2 || bundle.Warnings[1] != "IncompatibleFFmpegBuild" {
+		t.Errorf("incorrect warnings %v", bundle.Warnings)
+	}
+
+	if log := bundle.ChromaprintLogs["chromaprint"]; log != "Unknown format chromaprint" {
+		t.Errorf("incorrect chromaprint log %q", log)
+	}
+}
+
+func TestParseInvalidSupportBundle(t *testing.T) {
+	if _, err := parseSupportBundle("* Queue contents: many\n"); err == nil {
+		t.Error("expected an error for unparsable queue contents")
+	}
+
+	if _, err := parseSupportBundle("* Jellyfin version: 10.8.9\n"); err == nil {
+		t.Error("expected an error for a missing plugin version")
+	}
+
+	if _, err := parseSupportBundle(okayBundle + "FFmpeg chromaprint:\n```\nunterminated\n"); err == nil {
+		t.Error("expected an error for an unterminated log")
+	}
+}
+
+func TestCheckSupportBundle(t *testing.T) {
+	okay, _ := parseSupportBundle(okayBundle)
+	if problems := checkSupportBundle(okay, "0.1.8.0"); len(problems) != 0 {
+		t.Errorf("expected no problems, found %v", problems)
+	}
+
+	if problems := checkSupportBundle(okay, "0.1.9.0"); len(problems) != 1 {
+		t.Errorf("expected a version mismatch, found %v", problems)
+	}
+
+	broken, _ := parseSupportBundle(brokenBundle)
+	problems := checkSupportBundle(broken, "")
+	if len(problems) != 2 {
+		t.Fatalf("expected warnings and an FFmpeg error, found %v", problems)
+	}
+
+	if !strings.Contains(problems[1], "chromaprint_not_supported") {
+		t.Errorf("FFmpeg error was not reported: %s", problems[1])
+	}
+
+	silence := structs.SupportBundle{PluginVersion: "0.1.8", FFmpegError: "silencedetect_not_supported"}
+	if problems := checkSupportBundle(silence, ""); len(problems) != 1 {
+		t.Errorf("expected an FFmpeg error, found %v", problems)
+	}
+
+	// Other FFmpeg errors are not fatal
+	format := structs.SupportBundle{PluginVersion: "0.1.8", FFmpegError: "fp_format_not_supported"}
+	if problems := checkSupportBundle(format, ""); len(problems) != 0 {
+		t.Errorf("expected no problems, found %v", problems)
+	}
+}
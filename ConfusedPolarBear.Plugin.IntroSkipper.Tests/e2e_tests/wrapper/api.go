@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Sends a request to a Jellyfin server and returns the response body. An error is returned if the request fails, the
+// wrapper is interrupted or the server does not respond with 200 OK or 204 No Content.
+func apiRequest(log *Logger, method, url, body string) ([]byte, error) {
+	return apiRequestContext(runContext, log, method, url, body)
+}
+
+// Sends a request to a Jellyfin server like apiRequest, which is canceled once ctx is done.
+func apiRequestContext(ctx context.Context, log *Logger, method, url, body string) ([]byte, error) {
+	// Create the request
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer([]byte(body)))
+	if err != nil {
+		return nil, err
+	}
+
+	// Set required headers
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(
+		"X-Emby-Authorization",
+		`MediaBrowser Client="JF E2E Tests", Version="0.0.1", DeviceId="E2E", Device="E2E"`)
+
+	// Send it
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Debugf("%s %s", method, url)
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	log.Debugf("%s %s %d", method, url, res.StatusCode)
+
+	raw, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusOK {
+		return raw, fmt.Errorf("%s %s returned status code %d", method, url, res.StatusCode)
+	}
+
+	return raw, nil
+}
+
+// Sends a request to a Jellyfin server and unmarshals the JSON response into out.
+func apiJSON(log *Logger, method, url, body string, out interface{}) error {
+	raw, err := apiRequest(log, method, url, body)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("unable to parse response from %s: %w", url, err)
+	}
+
+	return nil
+}
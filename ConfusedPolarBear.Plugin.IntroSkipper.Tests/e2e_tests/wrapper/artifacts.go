@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Directory to save the artifacts of every run in. Each run is saved in a subdirectory named after its start time.
+var artifactsRoot string
+
+// Returns the directory that the artifacts of a server are saved in.
+func artifactsDirectory(run *serverRun) string {
+	name := unsafeCommentRegex.ReplaceAllString(serverName(run.index, run.server), "_")
+	return filepath.Join(artifactsRoot, fmt.Sprint(run.start.Unix()), name)
+}
+
+// Returns the directory containing the plugin's configuration and timestamps in a local server's configuration
+// directory.
+func pluginDataDirectory(server Server, configurationDirectory string) string {
+	plugins := path.Dir(pluginDirectory(server, configurationDirectory))
+	return path.Join(plugins, "configurations", "intros")
+}
+
+// Saves the container log, the plugin's timestamps, a listing of the fingerprint cache and any EDL files generated
+// since the run started before a local server is torn down.
+func stageArtifacts(run *serverRun) error {
+	if !run.server.Docker || run.server.ContainerName == "" || artifactsRoot == "" {
+		return errSkipStage
+	}
+
+	destination := artifactsDirectory(run)
+	if err := os.MkdirAll(destination, 0700); err != nil {
+		return fmt.Errorf("failed to create artifacts directory: %w", err)
+	}
+
+	run.log.Infof("Saving artifacts to %s", destination)
+
+	var failed []string
+	save := func(name string, err error) {
+		if err != nil {
+			run.log.Warnf("Unable to save %s: %s", name, err)
+			failed = append(failed, name)
+		}
+	}
+
+	// The container is removed as soon as it is stopped, so its log must be saved first, even after an interrupt
+	logs, err := run.runtime.Detached().Logs(run.server.ContainerName)
+	if err == nil {
+		err = os.WriteFile(filepath.Join(destination, "jellyfin.log"), []byte(redact(logs)), 0600)
+	}
+	save("container log", err)
+
+	data := pluginDataDirectory(run.server, run.configurationDirectory)
+	for _, name := range []string{"intros.xml", "credits.xml"} {
+		save(name, copyArtifact(path.Join(data, name), filepath.Join(destination, name)))
+	}
+
+	save("fingerprint cache listing", listDirectory(path.Join(data, "cache"), filepath.Join(destination, "cache.txt")))
+	save("EDL files", copyEdlFiles(run, filepath.Join(destination, "edl")))
+
+	run.details = fmt.Sprintf("saved to %s", destination)
+	if len(failed) > 0 {
+		return fmt.Errorf("unable to save %s", strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// Copies a file if it exists. Files are only created once the plugin has analyzed something, so a missing file is not
+// an error.
+func copyArtifact(source, destination string) error {
+	if err := copyFile(source, destination); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// Writes the name and size of every file in a directory, sorted by name.
+func listDirectory(directory, destination string) error {
+	entries, err := os.ReadDir(directory)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var listing strings.Builder
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(&listing, "%10d  %s\n", info.Size(), entry.Name())
+	}
+
+	return os.WriteFile(destination, []byte(listing.String()), 0600)
+}
+
+// Copies every EDL file from the writable copies of all libraries. Existing EDL files are not copied into them, so
+// every EDL file was written during the run. Files are saved under the name of their library and their path relative
+// to the library path they were found in.
+func copyEdlFiles(run *serverRun, destination string) error {
+	if !edlEnabled(run.server) {
+		return nil
+	}
+
+	for _, library := range writableLibraries(resolveLibraries(run.common), run.configurationDirectory) {
+		for _, mount := range library.Mounts {
+			root := mount.Source
+			err := filepath.WalkDir(root, func(source string, entry fs.DirEntry, err error) error {
+				if errors.Is(err, fs.ErrNotExist) && source == root {
+					return nil
+				} else if err != nil {
+					return err
+				} else if entry.IsDir() || !strings.EqualFold(filepath.Ext(source), ".edl") {
+					return nil
+				}
+
+				relative, err := filepath.Rel(root, source)
+				if err != nil {
+					return err
+				}
+
+				name := unsafeCommentRegex.ReplaceAllString(library.Name, "_")
+				target := filepath.Join(destination, name, relative)
+				if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+					return err
+				}
+
+				run.log.Infof("Saving %s", source)
+				return copyFile(source, target)
+			})
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
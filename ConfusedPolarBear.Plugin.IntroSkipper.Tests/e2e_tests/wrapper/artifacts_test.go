@@ -0,0 +1,171 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Writes a file, creating any parent directories.
+func writeTestFile(t *testing.T, name, contents string) {
+	if err := os.MkdirAll(filepath.Dir(name), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(name, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStageArtifacts(t *testing.T) {
+	captureLog(t)
+	registerTestSecrets(t, "hunter2")
+
+	oldRoot, oldPassword := artifactsRoot, containerPassword
+	t.Cleanup(func() { artifactsRoot, containerPassword = oldRoot, oldPassword })
+
+	artifactsRoot = t.TempDir()
+	containerPassword = "hunter2"
+
+	// Setup a configuration directory and library as the plugin would have left them
+	configuration, library := t.TempDir(), t.TempDir()
+	data := filepath.Join(configuration, "plugins", "configurations", "intros")
+	writeTestFile(t, filepath.Join(data, "intros.xml"), "<intros />")
+	writeTestFile(t, filepath.Join(data, "cache", "episode-1"), "fingerprint")
+	writeTestFile(t, filepath.Join(library, "TV", "Show", "S01E01.mkv"), "")
+	writeTestFile(t, filepath.Join(library, "TV", "Show", "S01E02.edl"), "0 5 3")
+
+	runtime := newFakeRuntime()
+	runtime.ContainerLogs["jf-e2e-1"] = "Authenticated admin with password hunter2\nGET /Items?api_key=key1\n"
+
+	run := &serverRun{
+		index: 1,
+		server: Server{
+			Comment:             "local server",
+			Docker:              true,
+			ContainerName:       "jf-e2e-1",
+			Image:               "jellyfin/jellyfin",
+			PluginConfiguration: map[string]interface{}{"EdlAction": "Intro"},
+		},
+		common:                 Common{Library: library},
+		start:                  time.Unix(1000, 0),
+		log:                    rootLog,
+		runtime:                runtime,
+		configurationDirectory: configuration,
+		apiKey:                 "key1",
+	}
+
+	// The plugin writes EDL files into the writable copy of the library
+	libraries := resolveLibraries(run.common)
+	writable := writableLibraries(libraries, configuration)
+	if err := copyLibraries(run.log, libraries, writable); err != nil {
+		t.Fatal(err)
+	}
+
+	writeTestFile(t, filepath.Join(writable[0].Mounts[0].Source, "TV", "Show", "S01E01.edl"), "0 10 3")
+
+	if err := stageArtifacts(run); err != nil {
+		t.Fatal(err)
+	}
+
+	destination := filepath.Join(artifactsRoot, "1000", "local_server")
+	if run.details != "saved to "+destination {
+		t.Errorf("incorrect details: %s", run.details)
+	}
+
+	read := func(name string) string {
+		contents, err := os.ReadFile(filepath.Join(destination, name))
+		if err != nil {
+			t.Error(err)
+		}
+
+		return string(contents)
+	}
+
+	if logs := read("jellyfin.log"); strings.Contains(logs, "hunter2") || strings.Contains(logs, "key1") {
+		t.Errorf("container log was not redacted: %s", logs)
+	}
+
+	if read("intros.xml") != "<intros />" {
+		t.Error("intros.xml was not saved")
+	}
+
+	// Credits were never analyzed
+	if _, err := os.Stat(filepath.Join(destination, "credits.xml")); !os.IsNotExist(err) {
+		t.Error("missing credits.xml was created")
+	}
+
+	if listing := read("cache.txt"); listing != "        11  episode-1\n" {
+		t.Errorf("incorrect cache listing: %q", listing)
+	}
+
+	// Only EDL files written during the run are saved, and the library itself is never written to
+	if read("edl/Shows/TV/Show/S01E01.edl") != "0 10 3" {
+		t.Error("EDL file was not saved")
+	}
+
+	if _, err := os.Stat(filepath.Join(library, "TV", "Show", "S01E01.edl")); !os.IsNotExist(err) {
+		t.Error("EDL file was written to the library")
+	}
+
+	if _, err := os.Stat(filepath.Join(destination, "edl", "Shows", "TV", "Show", "S01E02.edl")); !os.IsNotExist(err) {
+		t.Error("EDL file from before the run was saved")
+	}
+}
+
+func TestWritableLibraries(t *testing.T) {
+	captureLog(t)
+
+	library := t.TempDir()
+	writeTestFile(t, filepath.Join(library, "TV", "Show", "S01E01.mkv"), "video")
+
+	if edlEnabled(Server{}) || edlEnabled(Server{PluginConfiguration: map[string]interface{}{"EdlAction": "None"}}) {
+		t.Error("EDL generation is disabled by default")
+	}
+
+	if !edlEnabled(Server{PluginConfiguration: map[string]interface{}{"EdlAction": "Intro"}}) {
+		t.Error("EDL generation was not detected")
+	}
+
+	configuration := t.TempDir()
+	libraries := resolveLibraries(Common{Library: library})
+	writable := writableLibraries(libraries, configuration)
+
+	// Copies are created next to the library so that files can be hard linked into them
+	mount := writable[0].Mounts[0]
+	copied := filepath.Join(filepath.Dir(library), "."+filepath.Base(configuration)+"-0")
+	if mount.ReadOnly || mount.Target != "/media" || mount.Source != copied {
+		t.Errorf("incorrect writable mount: %+v", mount)
+	}
+
+	if !libraries[0].Mounts[0].ReadOnly {
+		t.Error("original library was modified")
+	}
+
+	if err := copyLibraries(rootLog, libraries, writable); err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := os.ReadFile(filepath.Join(mount.Source, "TV", "Show", "S01E01.mkv"))
+	if err != nil || string(contents) != "video" {
+		t.Errorf("media was not copied: %q (%v)", contents, err)
+	}
+
+	server := Server{PluginConfiguration: map[string]interface{}{"EdlAction": "Intro"}}
+	if err := removeWritableLibraries(rootLog, server, libraries, configuration); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(mount.Source); !os.IsNotExist(err) {
+		t.Error("writable copy was not deleted")
+	}
+}
+
+func TestStageArtifactsRemote(t *testing.T) {
+	run := &serverRun{server: Server{Address: "http://127.0.0.1:8096"}}
+	if err := stageArtifacts(run); err != errSkipStage {
+		t.Errorf("artifacts were collected from a remote server: %v", err)
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Outcome of a manual checkpoint.
+type CheckpointOutcome string
+
+const (
+	CheckpointPassed  CheckpointOutcome = "pass"
+	CheckpointFailed  CheckpointOutcome = "fail"
+	CheckpointNoted   CheckpointOutcome = "note"
+	CheckpointSkipped CheckpointOutcome = "skipped"
+)
+
+// Result of a single manual checkpoint.
+type CheckpointResult struct {
+	Description string
+	Outcome     CheckpointOutcome
+	Duration    time.Duration
+
+	// Reason given by the tester, or why no outcome was recorded.
+	Note string
+}
+
+// A line entered by the tester and when it was read.
+type checkpointLine struct {
+	text string
+	read time.Time
+}
+
+// Maximum number of lines which are kept until a checkpoint reads them. Further lines are discarded.
+const checkpointLineBuffer = 64
+
+// Input that checkpoint outcomes are read from. Only one checkpoint prompts at a time, even when servers are tested
+// in parallel.
+var checkpointInput struct {
+	sync.Mutex
+	reader io.Reader
+
+	// Lines read from reader. Closed once reader has no more input.
+	lines chan checkpointLine
+
+	// Whether reader is a terminal. Lines typed into a terminal before a prompt is shown were not meant for it, while
+	// piped input is expected to contain the outcomes of several checkpoints ahead of time.
+	interactive bool
+
+	// When the last checkpoint gave up waiting for an outcome. Lines read after this and before the next prompt was
+	// shown were entered too late and are ignored.
+	abandoned time.Time
+}
+
+func init() {
+	checkpointInput.reader = os.Stdin
+	checkpointInput.interactive = isTerminal(os.Stdin)
+}
+
+// Returns the lines entered by the tester, starting to read them if necessary. Lines are read as soon as they are
+// available, even if no checkpoint is waiting for them, so that the time they were read at is known. Must be called
+// with the lock held.
+func checkpointLines() <-chan checkpointLine {
+	if checkpointInput.lines != nil {
+		return checkpointInput.lines
+	}
+
+	lines := make(chan checkpointLine, checkpointLineBuffer)
+	checkpointInput.lines = lines
+
+	go func(reader io.Reader) {
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			select {
+			case lines <- checkpointLine{text: scanner.Text(), read: time.Now()}:
+			default:
+			}
+		}
+
+		close(lines)
+	}(checkpointInput.reader)
+
+	return lines
+}
+
+// Parses an outcome entered by the tester. An empty line passes the checkpoint.
+func parseCheckpointOutcome(line string) (CheckpointOutcome, string, error) {
+	parts := strings.SplitN(strings.TrimSpace(line), " ", 2)
+
+	note := ""
+	if len(parts) == 2 {
+		note = strings.TrimSpace(parts[1])
+	}
+
+	switch strings.ToLower(parts[0]) {
+	case "", "p", "pass":
+		return CheckpointPassed, note, nil
+
+	case "f", "fail":
+		return CheckpointFailed, note, nil
+
+	case "n", "note":
+		if note == "" {
+			return "", "", errors.New("a note is required")
+		}
+
+		return CheckpointNoted, note, nil
+	}
+
+	return "", "", fmt.Errorf("unknown outcome %q", parts[0])
+}
+
+// Prompts the tester to perform a checkpoint and waits for its outcome, until the checkpoint's timeout elapses or
+// there is no more input.
+func runCheckpoint(run *serverRun, number int, checkpoint Checkpoint) CheckpointResult {
+	checkpointInput.Lock()
+	defer checkpointInput.Unlock()
+
+	result := CheckpointResult{Description: checkpoint.Description}
+	start := time.Now()
+	defer func() { result.Duration = time.Since(start) }()
+
+	// Timeouts were validated when the configuration was loaded
+	timeout, _ := checkpoint.timeout()
+
+	var expired <-chan time.Time
+	waiting := "waiting indefinitely"
+	if timeout > 0 {
+		expired = time.After(timeout)
+		waiting = fmt.Sprintf("waiting up to %s", timeout)
+	}
+
+	// If no outcome is recorded in time, either continue or fail the checkpoint
+	giveUp := func(reason string) CheckpointResult {
+		checkpointInput.abandoned = time.Now()
+		result.Outcome, result.Note = CheckpointFailed, reason
+		if checkpoint.AutoContinue {
+			result.Outcome = CheckpointSkipped
+			run.log.Warnf("Continuing without an outcome: %s", reason)
+		}
+
+		return result
+	}
+
+	abandoned, shown := checkpointInput.abandoned, time.Now()
+	checkpointInput.abandoned = time.Time{}
+	run.log.Warnf("Checkpoint %d/%d: %s", number, len(run.server.Checkpoints), checkpoint.Description)
+	run.log.Infof("Enter pass (or press enter), fail [reason] or note <text> (%s)", waiting)
+
+	lines := checkpointLines()
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				return giveUp("no input is available")
+			}
+
+			// Lines typed before the prompt was shown, such as an extra enter after the previous checkpoint, and answers to
+			// an earlier checkpoint which gave up waiting must not be used for this one
+			late := !abandoned.IsZero() && line.read.After(abandoned)
+			if line.read.Before(shown) && (checkpointInput.interactive || late) {
+				run.log.Warnf("Ignoring %q, which was entered before this checkpoint was shown", line.text)
+				continue
+			}
+
+			outcome, note, err := parseCheckpointOutcome(line.text)
+			if err != nil {
+				run.log.Warnf("%s, enter pass, fail [reason] or note <text>", err)
+				continue
+			}
+
+			run.log.Infof("Checkpoint %d/%d: %s", number, len(run.server.Checkpoints), outcome)
+			result.Outcome, result.Note = outcome, note
+			return result
+
+		case <-expired:
+			return giveUp(fmt.Sprintf("no outcome was entered within %s", timeout))
+
+		case <-runContext.Done():
+			result.Outcome, result.Note = CheckpointFailed, "testing was interrupted"
+			return result
+		}
+	}
+}
+
+// Waits for the tester to perform every manual checkpoint. Fails if any checkpoint failed.
+func stageCheckpoints(run *serverRun) error {
+	if len(run.server.Checkpoints) == 0 {
+		return errSkipStage
+	}
+
+	counts := make(map[CheckpointOutcome]int)
+	var failed []string
+
+	for i, checkpoint := range run.server.Checkpoints {
+		result := runCheckpoint(run, i+1, checkpoint)
+		run.checkpoints = append(run.checkpoints, result)
+		counts[result.Outcome]++
+
+		if result.Outcome == CheckpointFailed {
+			failed = append(failed, fmt.Sprintf("%q", checkpoint.Description))
+		}
+
+		if interrupted() {
+			break
+		}
+	}
+
+	run.details = fmt.Sprintf("%d passed, %d failed, %d noted, %d skipped",
+		counts[CheckpointPassed],
+		counts[CheckpointFailed],
+		counts[CheckpointNoted],
+		counts[CheckpointSkipped])
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed checkpoints: %s", strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// Describes a checkpoint along with how long its outcome is waited for.
+func describeCheckpoint(checkpoint Checkpoint) string {
+	timeout, _ := checkpoint.timeout()
+
+	switch {
+	case timeout == 0:
+		return checkpoint.Description
+
+	case checkpoint.AutoContinue:
+		return fmt.Sprintf("%s (continues after %s)", checkpoint.Description, timeout)
+
+	default:
+		return fmt.Sprintf("%s (fails after %s)", checkpoint.Description, timeout)
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Reads checkpoint outcomes from the provided reader until the test finishes.
+func checkpointTestInput(t *testing.T, reader io.Reader) {
+	checkpointInput.Lock()
+	defer checkpointInput.Unlock()
+
+	oldReader, oldLines, oldInteractive := checkpointInput.reader, checkpointInput.lines, checkpointInput.interactive
+	t.Cleanup(func() {
+		checkpointInput.Lock()
+		defer checkpointInput.Unlock()
+		checkpointInput.reader, checkpointInput.lines, checkpointInput.interactive = oldReader, oldLines, oldInteractive
+	})
+
+	checkpointInput.reader, checkpointInput.lines, checkpointInput.interactive = reader, nil, false
+}
+
+func TestParseCheckpointOutcome(t *testing.T) {
+	for line, expected := range map[string]CheckpointOutcome{
+		"":                  CheckpointPassed,
+		"  PASS ":           CheckpointPassed,
+		"f":                 CheckpointFailed,
+		"fail button shown": CheckpointFailed,
+		"note a bit late":   CheckpointNoted,
+	} {
+		if outcome, _, err := parseCheckpointOutcome(line); err != nil || outcome != expected {
+			t.Errorf("%q was parsed as %q (%v), expected %q", line, outcome, err, expected)
+		}
+	}
+
+	if _, note, _ := parseCheckpointOutcome("fail  button  shown "); note != "button  shown" {
+		t.Errorf("incorrect note: %q", note)
+	}
+
+	for _, line := range []string{"note", "maybe"} {
+		if _, _, err := parseCheckpointOutcome(line); err == nil {
+			t.Errorf("%q was accepted", line)
+		}
+	}
+}
+
+func TestCheckpoints(t *testing.T) {
+	captureLog(t)
+	checkpointTestInput(t, strings.NewReader("maybe\n\nfail button was hidden\nn a bit late\n"))
+
+	run := &serverRun{log: rootLog, server: Server{Checkpoints: []Checkpoint{
+		{Description: "Skip button is shown"},
+		{Description: "Skip button hides"},
+		{Description: "Skip button timing"},
+		{Description: "Credits", AutoContinue: true},
+	}}}
+
+	err := stageCheckpoints(run)
+	if err == nil || !strings.Contains(err.Error(), `"Skip button hides"`) {
+		t.Errorf("failed checkpoint was not reported: %v", err)
+	}
+
+	var outcomes []string
+	for _, result := range run.checkpoints {
+		outcomes = append(outcomes, string(result.Outcome)+":"+result.Note)
+	}
+
+	expected := "pass:,fail:button was hidden,note:a bit late,skipped:no input is available"
+	if actual := strings.Join(outcomes, ","); actual != expected {
+		t.Errorf("incorrect outcomes: %s", actual)
+	}
+
+	if run.details != "1 passed, 1 failed, 1 noted, 1 skipped" {
+		t.Errorf("incorrect details: %s", run.details)
+	}
+}
+
+func TestCheckpointTimeout(t *testing.T) {
+	captureLog(t)
+
+	// Never provides any input
+	reader, writer := io.Pipe()
+	defer writer.Close()
+	checkpointTestInput(t, reader)
+
+	run := &serverRun{log: rootLog, server: Server{Checkpoints: []Checkpoint{
+		{Description: "continues", Timeout: "10ms", AutoContinue: true},
+		{Description: "fails", Timeout: "10ms"},
+	}}}
+
+	if err := stageCheckpoints(run); err == nil || !strings.Contains(err.Error(), `"fails"`) {
+		t.Errorf("timed out checkpoint did not fail: %v", err)
+	}
+
+	if len(run.checkpoints) != 2 || run.checkpoints[0].Outcome != CheckpointSkipped ||
+		!strings.Contains(run.checkpoints[1].Note, "within 10ms") {
+		t.Errorf("incorrect outcomes: %+v", run.checkpoints)
+	}
+}
+
+func TestCheckpointLateInput(t *testing.T) {
+	captureLog(t)
+
+	reader, writer := io.Pipe()
+	defer writer.Close()
+	checkpointTestInput(t, reader)
+
+	run := &serverRun{log: rootLog, server: Server{Checkpoints: []Checkpoint{
+		{Description: "first", Timeout: "10ms", AutoContinue: true},
+		{Description: "second", Timeout: "5s"},
+	}}}
+
+	first := runCheckpoint(run, 1, run.server.Checkpoints[0])
+
+	// Answer the first checkpoint after it gave up waiting
+	io.WriteString(writer, "pass\n")
+	time.Sleep(50 * time.Millisecond)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		io.WriteString(writer, "fail late answer was ignored\n")
+	}()
+
+	second := runCheckpoint(run, 2, run.server.Checkpoints[1])
+
+	if first.Outcome != CheckpointSkipped {
+		t.Errorf("first checkpoint did not time out: %+v", first)
+	}
+
+	if second.Outcome != CheckpointFailed || second.Note != "late answer was ignored" {
+		t.Errorf("late input was used for the second checkpoint: %+v", second)
+	}
+}
+
+func TestCheckpointInteractiveInput(t *testing.T) {
+	captureLog(t)
+
+	reader, writer := io.Pipe()
+	defer writer.Close()
+	checkpointTestInput(t, reader)
+	checkpointInput.interactive = true
+
+	run := &serverRun{log: rootLog, server: Server{Checkpoints: []Checkpoint{
+		{Description: "first", Timeout: "5s"},
+		{Description: "second", Timeout: "100ms", AutoContinue: true},
+	}}}
+
+	// The tester presses enter twice at the first checkpoint
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		io.WriteString(writer, "\n\n")
+	}()
+
+	first := runCheckpoint(run, 1, run.server.Checkpoints[0])
+	time.Sleep(50 * time.Millisecond)
+	second := runCheckpoint(run, 2, run.server.Checkpoints[1])
+
+	if first.Outcome != CheckpointPassed {
+		t.Errorf("first checkpoint did not pass: %+v", first)
+	}
+
+	if second.Outcome != CheckpointSkipped {
+		t.Errorf("second checkpoint was answered before it was shown: %+v", second)
+	}
+}
+
+func TestCheckpointSummary(t *testing.T) {
+	results := []ServerResult{{
+		Name:   "manual",
+		Stages: []StageResult{{Name: "checkpoints", Status: StageFailed}},
+		Checkpoints: []CheckpointResult{
+			{Description: "Skip button is shown", Outcome: CheckpointPassed},
+			{Description: "Skip button hides", Outcome: CheckpointFailed, Note: "still visible"},
+			{Description: "Credits", Outcome: CheckpointSkipped, Note: "no input is available"},
+		},
+	}}
+
+	var table bytes.Buffer
+	printSummary(&table, results)
+	if !strings.Contains(table.String(), "CHECKPOINT") || !strings.Contains(table.String(), "still visible") {
+		t.Errorf("summary does not include checkpoints: %s", table.String())
+	}
+
+	report := junitReport(results)
+	if report.Tests != 4 || report.Failures != 2 || report.Skipped != 1 {
+		t.Errorf("incorrect JUnit totals: %+v", report)
+	}
+
+	if name := report.Suites[0].TestCases[2].Name; name != "checkpoint: Skip button hides" {
+		t.Errorf("incorrect test case name: %s", name)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Removes containers, configuration directories and Selenium services left behind by earlier runs which crashed or
+// were killed before they could clean up. Every installed container runtime is checked. Returns the process exit code.
+func cleanupLeftovers() int {
+	failed := false
+
+	for _, name := range []string{"docker", "podman"} {
+		if _, err := exec.LookPath(name); err != nil {
+			continue
+		}
+
+		runtime, err := newContainerRuntime(name, rootLog)
+		if err != nil {
+			panic(err)
+		}
+
+		rootLog.Infof("Removing leftover containers with %s", name)
+		if err := removeContainers(rootLog, runtime); err != nil {
+			rootLog.Errorf("Unable to remove containers: %s", err)
+			failed = true
+		}
+
+		// Selenium may have been started with either runtime, so failing to stop it with the other one is expected
+		rootLog.Infof("Stopping Selenium with %s", name)
+		if err := runtime.ComposeDown(); err != nil {
+			rootLog.Warnf("Unable to stop Selenium: %s", err)
+		}
+	}
+
+	rootLog.Infof("Removing leftover configuration directories from %s", configurationRoot)
+	if err := removeConfigurationDirectories(rootLog, configurationRoot); err != nil {
+		rootLog.Errorf("Unable to remove configuration directories: %s", err)
+		failed = true
+	}
+
+	// Writable library copies are created next to the configured libraries, which are only known from the configuration
+	if config, err := loadConfiguration(configPath); err != nil {
+		rootLog.Warnf("Unable to load configuration, leftover library copies were not removed: %s", err)
+	} else if err := removeLibraryCopies(rootLog, resolveLibraries(config.Common)); err != nil {
+		rootLog.Errorf("Unable to remove library copies: %s", err)
+		failed = true
+	}
+
+	if failed {
+		return 1
+	}
+
+	rootLog.Infof("Cleanup finished")
+	return 0
+}
+
+// Removes every container which was started to test a local server. Containers are found by their label and, for
+// containers started before labels were added, by their name.
+func removeContainers(log *Logger, runtime ContainerRuntime) error {
+	labeled, err := runtime.List("label=" + containerLabel)
+	if err != nil {
+		return err
+	}
+
+	named, err := runtime.List("name=" + containerName)
+	if err != nil {
+		return err
+	}
+
+	// Every labeled container was started by us. Name filters match anywhere in the name, so only remove containers
+	// found by their name if they were named by us. Before servers were given unique names, the only container was
+	// named exactly jf-e2e.
+	candidates := labeled
+	for _, name := range named {
+		if name == containerName || strings.HasPrefix(name, containerName+"-") {
+			candidates = append(candidates, name)
+		}
+	}
+
+	var failures []string
+	removed := make(map[string]bool)
+
+	for _, name := range candidates {
+		if removed[name] {
+			continue
+		}
+
+		removed[name] = true
+
+		log.Infof("Removing container %s", name)
+		if err := runtime.Remove(name); err != nil {
+			failures = append(failures, name)
+		}
+	}
+
+	if len(failures) > 0 {
+		return errors.New("failed to remove " + strings.Join(failures, ", "))
+	}
+
+	return nil
+}
+
+// Deletes every temporary configuration directory of a local server in root.
+func removeConfigurationDirectories(log *Logger, root string) error {
+	return removeDirectories(log, filepath.Join(root, containerName+"-*"))
+}
+
+// Deletes every writable copy created next to the paths of the libraries.
+func removeLibraryCopies(log *Logger, libraries []Library) error {
+	for _, mount := range libraryMounts(libraries) {
+		if err := removeDirectories(log, filepath.Join(filepath.Dir(mount.Source), "."+containerName+"-*")); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Deletes every directory which matches pattern.
+func removeDirectories(log *Logger, pattern string) error {
+	directories, err := filepath.Glob(pattern)
+	if err != nil {
+		return err
+	}
+
+	var failures []string
+
+	for _, directory := range directories {
+		if info, err := os.Stat(directory); err != nil || !info.IsDir() {
+			continue
+		}
+
+		log.Infof("Deleting %s", directory)
+		if err := os.RemoveAll(directory); err != nil {
+			failures = append(failures, directory)
+		}
+	}
+
+	if len(failures) > 0 {
+		return errors.New("failed to delete " + strings.Join(failures, ", "))
+	}
+
+	return nil
+}
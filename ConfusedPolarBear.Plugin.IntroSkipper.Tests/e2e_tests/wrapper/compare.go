@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// Returns the path to the report that a server's analysis results should be compared against, or an empty string if
+// no baseline is configured or no previous report could be found. The current report is never used as the baseline.
+func resolveBaseline(server Server, current string) (string, error) {
+	switch server.Baseline {
+	case "":
+		return "", nil
+
+	case "latest":
+		return latestReport(filepath.Dir(current), server.Comment, current)
+
+	default:
+		if _, err := os.Stat(server.Baseline); err != nil {
+			return "", fmt.Errorf("unable to read baseline: %w", err)
+		}
+
+		return server.Baseline, nil
+	}
+}
+
+// Returns the most recent non-empty report in directory generated for the server with the provided comment,
+// excluding the current report.
+func latestReport(directory, comment, current string) (string, error) {
+	matches, err := filepath.Glob(filepath.Join(directory, comment+"-*.json"))
+	if err != nil {
+		return "", err
+	}
+
+	// Reports are named after the Unix timestamp of the run they were generated in
+	type candidate struct {
+		path      string
+		timestamp int64
+	}
+
+	var candidates []candidate
+	prefix := filepath.Join(directory, comment+"-")
+
+	for _, match := range matches {
+		if filepath.Clean(match) == filepath.Clean(current) {
+			continue
+		}
+
+		raw := match[len(prefix) : len(match)-len(".json")]
+		timestamp, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		// Skip reports left behind by runs that failed before saving anything
+		if info, err := os.Stat(match); err != nil || info.Size() == 0 {
+			continue
+		}
+
+		candidates = append(candidates, candidate{path: match, timestamp: timestamp})
+	}
+
+	if len(candidates) == 0 {
+		return "", nil
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].timestamp > candidates[j].timestamp
+	})
+
+	return candidates[0].path, nil
+}
+
+// Returns the verifier arguments used to compare a report against a baseline and enforce the provided thresholds.
+func comparisonArguments(baseline, report, output string, thresholds Thresholds) []string {
+	args := []string{"-r1", baseline, "-r2", report, "-o", output}
+
+	if thresholds.Tolerance != nil {
+		args = append(args, "-tolerance", strconv.Itoa(*thresholds.Tolerance))
+	}
+
+	if thresholds.MaxLost != nil {
+		args = append(args, "-max-lost", strconv.Itoa(*thresholds.MaxLost))
+	}
+
+	if thresholds.MaxChanged != nil {
+		args = append(args, "-max-changed", strconv.Itoa(*thresholds.MaxChanged))
+	}
+
+	if thresholds.MaxGained != nil {
+		args = append(args, "-max-gained", strconv.Itoa(*thresholds.MaxGained))
+	}
+
+	if thresholds.MinOkay > 0 {
+		args = append(args, "-min-okay", strconv.FormatFloat(thresholds.MinOkay, 'f', -1, 64))
+	}
+
+	if thresholds.MaxRuntimeIncrease != nil {
+		args = append(args, "-max-runtime-increase", strconv.FormatFloat(*thresholds.MaxRuntimeIncrease, 'f', -1, 64))
+	}
+
+	if thresholds.MaxCPUIncrease != nil {
+		args = append(args, "-max-cpu-increase", strconv.FormatFloat(*thresholds.MaxCPUIncrease, 'f', -1, 64))
+	}
+
+	if thresholds.MaxMemoryIncrease != nil {
+		args = append(args, "-max-memory-increase", strconv.FormatFloat(*thresholds.MaxMemoryIncrease, 'f', -1, 64))
+	}
+
+	return args
+}
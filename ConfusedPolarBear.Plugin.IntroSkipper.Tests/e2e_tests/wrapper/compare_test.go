@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLatestReport(t *testing.T) {
+	directory := t.TempDir()
+
+	write := func(name, contents string) string {
+		path := filepath.Join(directory, name)
+		if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		return path
+	}
+
+	write("server-100.json", "{}")
+	expected := write("server-200.json", "{}")
+	write("server-300.json", "")
+	write("other-400.json", "{}")
+	current := write("server-500.json", "{}")
+
+	baseline, err := resolveBaseline(Server{Comment: "server", Baseline: "latest"}, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if baseline != expected {
+		t.Errorf("expected baseline %s, found %s", expected, baseline)
+	}
+
+	// A server without any previous reports has no baseline
+	baseline, err = resolveBaseline(Server{Comment: "new", Baseline: "latest"}, current)
+	if err != nil || baseline != "" {
+		t.Errorf("expected no baseline, found %q (%v)", baseline, err)
+	}
+
+	if _, err := resolveBaseline(Server{Baseline: filepath.Join(directory, "missing.json")}, current); err == nil {
+		t.Error("missing baseline should return an error")
+	}
+}
+
+func TestComparisonArguments(t *testing.T) {
+	zero, increase := 0, 12.5
+	args := comparisonArguments("old.json", "new.json", "new.html",
+		Thresholds{Tolerance: &zero, MaxLost: &zero, MinOkay: 97.5, MaxMemoryIncrease: &increase})
+
+	expected := "-r1 old.json -r2 new.json -o new.html -tolerance 0 -max-lost 0 -min-okay 97.5 -max-memory-increase 12.5"
+	if actual := strings.Join(args, " "); actual != expected {
+		t.Errorf("incorrect arguments: %s", actual)
+	}
+
+	// The verifier's default tolerance is used unless one is set
+	args = comparisonArguments("old.json", "new.json", "new.html", Thresholds{})
+	if actual := strings.Join(args, " "); actual != "-r1 old.json -r2 new.json -o new.html" {
+		t.Errorf("incorrect default arguments: %s", actual)
+	}
+}
@@ -0,0 +1,478 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Browsers and Selenium tests supported by selenium/main.py.
+var (
+	supportedBrowsers = []string{"chrome", "firefox"}
+	supportedTests    = []string{"skip_button", "settings"}
+)
+
+// All problems found in a configuration file.
+type configurationErrors []error
+
+func (e configurationErrors) Error() string {
+	var messages []string
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// Parses a JSONC configuration file (JSON with comments and trailing commas), applies all overrides and validates
+// the result. All problems found are returned at once as configurationErrors.
+func parseConfiguration(raw []byte, overrides []override) (Configuration, error) {
+	var config Configuration
+
+	stripped := stripJSONC(raw)
+
+	// Check the syntax and find any fields that are not part of the configuration
+	var generic interface{}
+	if err := json.Unmarshal(stripped, &generic); err != nil {
+		return config, describeJSONError(stripped, err)
+	}
+
+	problems := unknownFields(generic, reflect.TypeOf(config), "")
+	if len(problems) > 0 {
+		return config, problems
+	}
+
+	// Overridden values are not part of the original file, so line numbers are only reported without overrides
+	if len(overrides) > 0 {
+		if err := applyOverrides(generic, overrides); err != nil {
+			return config, err
+		}
+
+		patched, err := json.Marshal(generic)
+		if err != nil {
+			return config, err
+		}
+
+		if err := json.Unmarshal(patched, &config); err != nil {
+			return config, describeJSONError(nil, err)
+		}
+	} else if err := json.Unmarshal(stripped, &config); err != nil {
+		return config, describeJSONError(stripped, err)
+	}
+
+	if problems := validateConfiguration(config); len(problems) > 0 {
+		return config, problems
+	}
+
+	expandMatrices(&config)
+
+	if problems := validateComments(config.Servers); len(problems) > 0 {
+		return config, problems
+	}
+
+	return config, nil
+}
+
+// Checks that no two servers share a comment. Reports and everything derived from them are named after the comment,
+// so servers with the same comment would overwrite each other's results. Servers without a comment are named after
+// their index instead. Must be called after matrices are expanded, since expanded servers are named by the matrix.
+func validateComments(servers []Server) configurationErrors {
+	var problems configurationErrors
+	seen := make(map[string]int)
+
+	for i, server := range servers {
+		if server.Comment == "" {
+			continue
+		}
+
+		if first, ok := seen[server.Comment]; ok {
+			problems = append(problems, fmt.Errorf("servers[%d].comment: %q is already used by servers[%d]",
+				i, server.Comment, first))
+			continue
+		}
+
+		seen[server.Comment] = i
+	}
+
+	return problems
+}
+
+// Checks that all values in the configuration are supported.
+func validateConfiguration(config Configuration) configurationErrors {
+	var problems configurationErrors
+
+	add := func(field, format string, args ...interface{}) {
+		problems = append(problems, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+
+	switch config.Common.Runtime {
+	case "", "docker", "podman":
+	default:
+		add("common.runtime", "unsupported container runtime %q (expected docker or podman)", config.Common.Runtime)
+	}
+
+	if config.Common.MaxParallelism < 0 {
+		add("common.max_parallelism", "must not be negative")
+	}
+
+	problems = append(problems, validateLibraries(config.Common.Libraries)...)
+	problems = append(problems, validateReadiness(config.Common.Readiness)...)
+
+	if len(config.Servers) == 0 && len(config.Matrix) == 0 {
+		add("servers", "at least one server or matrix is required")
+	}
+
+	for i, server := range config.Servers {
+		problems = append(problems, validateServer(fmt.Sprintf("servers[%d]", i), server, config.Common)...)
+	}
+
+	for i, matrix := range config.Matrix {
+		prefix := fmt.Sprintf("matrix[%d]", i)
+
+		if len(matrix.Images) == 0 {
+			add(prefix+".images", "at least one image is required")
+		}
+
+		if matrix.Template.Image != "" || matrix.Template.Address != "" {
+			add(prefix+".template", "images are set by the matrix, remove the template's image and address")
+		}
+
+		for j, browser := range matrix.Browsers {
+			if !contains(supportedBrowsers, browser) {
+				add(fmt.Sprintf("%s.browsers[%d]", prefix, j), "unknown browser %q (expected one of %s)",
+					browser, strings.Join(supportedBrowsers, ", "))
+			}
+		}
+
+		// Validate the template as if it was a local server
+		if len(matrix.Images) > 0 {
+			template := matrix.Template
+			template.Image = matrix.Images[0]
+			template.Address = ""
+			problems = append(problems, validateServer(prefix+".template", template, config.Common)...)
+		}
+	}
+
+	return problems
+}
+
+// Checks that all values in a server's configuration are supported.
+func validateServer(prefix string, server Server, common Common) configurationErrors {
+	var problems configurationErrors
+
+	add := func(field, format string, args ...interface{}) {
+		problems = append(problems, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+
+	if server.Image == "" && server.Address == "" {
+		add(prefix, "either address or image is required")
+	}
+
+	if server.Image != "" && common.Library == "" && len(common.Libraries) == 0 {
+		add(prefix+".image", "local containers require common.library or common.libraries to be set")
+	}
+
+	for j, browser := range server.Browsers {
+		if !contains(supportedBrowsers, browser) {
+			add(fmt.Sprintf("%s.browsers[%d]", prefix, j), "unknown browser %q (expected one of %s)",
+				browser, strings.Join(supportedBrowsers, ", "))
+		}
+	}
+
+	for j, test := range server.Tests {
+		if !contains(supportedTests, test) {
+			add(fmt.Sprintf("%s.tests[%d]", prefix, j), "unknown test %q (expected one of %s)",
+				test, strings.Join(supportedTests, ", "))
+		}
+	}
+
+	if server.Baseline == "latest" && server.Comment == "" {
+		add(prefix+".baseline", "a comment is required to find the server's previous reports")
+	}
+
+	for j, checkpoint := range server.Checkpoints {
+		field := fmt.Sprintf("%s.checkpoints[%d]", prefix, j)
+
+		if strings.TrimSpace(checkpoint.Description) == "" {
+			add(field+".description", "a description of what to check is required")
+		}
+
+		if timeout, err := checkpoint.timeout(); err != nil {
+			add(field+".timeout", "invalid duration %q (expected a value such as 30s or 5m)", checkpoint.Timeout)
+		} else if timeout < 0 {
+			add(field+".timeout", "must not be negative")
+		}
+	}
+
+	switch server.Scenario {
+	case "":
+	case scenarioUpgrade:
+		if server.Image == "" {
+			add(prefix+".scenario", "upgrades can only be tested on local containers")
+		}
+
+		if server.UpgradeFrom == "" {
+			add(prefix+".upgrade_from", "path to the plugin DLL to upgrade from is required")
+		}
+
+	default:
+		add(prefix+".scenario", "unknown scenario %q (expected %s)", server.Scenario, scenarioUpgrade)
+	}
+
+	switch server.Install {
+	case "", installCopy:
+	case installRepository:
+		if server.Image == "" {
+			add(prefix+".install", "only local containers can install the plugin from a repository")
+		}
+
+		if server.Scenario == scenarioUpgrade {
+			add(prefix+".install", "upgrades can only be tested by copying the plugin")
+		}
+
+	default:
+		add(prefix+".install", "unknown install method %q (expected %s or %s)", server.Install, installCopy, installRepository)
+	}
+
+	thresholds := server.Thresholds
+	if thresholds.Tolerance != nil && *thresholds.Tolerance < 0 {
+		add(prefix+".thresholds.tolerance", "must not be negative")
+	}
+
+	if thresholds.MaxLost != nil && *thresholds.MaxLost < 0 {
+		add(prefix+".thresholds.max_lost", "must not be negative")
+	}
+
+	if thresholds.MaxChanged != nil && *thresholds.MaxChanged < 0 {
+		add(prefix+".thresholds.max_changed", "must not be negative")
+	}
+
+	if thresholds.MaxGained != nil && *thresholds.MaxGained < 0 {
+		add(prefix+".thresholds.max_gained", "must not be negative")
+	}
+
+	if thresholds.MinOkay < 0 || thresholds.MinOkay > 100 {
+		add(prefix+".thresholds.min_okay", "must be a percentage between 0 and 100")
+	}
+
+	if thresholds.MaxRuntimeIncrease != nil && *thresholds.MaxRuntimeIncrease < 0 {
+		add(prefix+".thresholds.max_runtime_increase", "must not be negative")
+	}
+
+	if thresholds.MaxCPUIncrease != nil && *thresholds.MaxCPUIncrease < 0 {
+		add(prefix+".thresholds.max_cpu_increase", "must not be negative")
+	}
+
+	if thresholds.MaxMemoryIncrease != nil && *thresholds.MaxMemoryIncrease < 0 {
+		add(prefix+".thresholds.max_memory_increase", "must not be negative")
+	}
+
+	return problems
+}
+
+// Returns an error for every object key in value which does not correspond to a field of typ.
+func unknownFields(value interface{}, typ reflect.Type, path string) configurationErrors {
+	var problems configurationErrors
+
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if typ.Kind() != reflect.Struct {
+			return nil
+		}
+
+		fields := jsonFields(typ)
+
+		// Sort keys so errors are reported in a stable order
+		var keys []string
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			field := key
+			if path != "" {
+				field = path + "." + key
+			}
+
+			fieldType, ok := fields[key]
+			if !ok {
+				problems = append(problems, fmt.Errorf("%s: unknown field", field))
+				continue
+			}
+
+			problems = append(problems, unknownFields(v[key], fieldType, field)...)
+		}
+
+	case []interface{}:
+		if typ.Kind() != reflect.Slice {
+			return nil
+		}
+
+		for i, element := range v {
+			problems = append(problems, unknownFields(element, typ.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	}
+
+	return problems
+}
+
+// Returns the JSON name and type of every field of a struct which can be set from JSON.
+func jsonFields(typ reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" || field.PkgPath != "" {
+			continue
+		} else if name == "" {
+			name = field.Name
+		}
+
+		fields[name] = field.Type
+	}
+
+	return fields
+}
+
+// Adds the line and column to JSON syntax and type errors.
+func describeJSONError(raw []byte, err error) error {
+	var offset int64
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+		err = fmt.Errorf("%s: expected %s but found %s", typeErr.Field, typeErr.Type, typeErr.Value)
+
+	default:
+		return err
+	}
+
+	if raw == nil {
+		return err
+	}
+
+	if offset > int64(len(raw)) {
+		offset = int64(len(raw))
+	}
+
+	before := raw[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+
+	return fmt.Errorf("line %d, column %d: %w", line, column, err)
+}
+
+// Converts JSONC to JSON by removing comments and trailing commas. Removed characters are replaced with spaces
+// (newlines are kept) so that offsets in the result match the original file.
+func stripJSONC(raw []byte) []byte {
+	out := make([]byte, len(raw))
+	copy(out, raw)
+
+	blank := func(start, end int) {
+		for i := start; i < end; i++ {
+			if out[i] != '\n' {
+				out[i] = ' '
+			}
+		}
+	}
+
+	inString := false
+	for i := 0; i < len(out); i++ {
+		c := out[i]
+
+		if inString {
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+
+			continue
+		}
+
+		switch {
+		case c == '"':
+			inString = true
+
+		case c == '/' && i+1 < len(out) && out[i+1] == '/':
+			end := bytes.IndexByte(out[i:], '\n')
+			if end == -1 {
+				end = len(out) - i
+			}
+
+			blank(i, i+end)
+			i += end - 1
+
+		case c == '/' && i+1 < len(out) && out[i+1] == '*':
+			end := bytes.Index(out[i+2:], []byte("*/"))
+			if end == -1 {
+				blank(i, len(out))
+				return out
+			}
+
+			blank(i, i+2+end+2)
+			i += 2 + end + 1
+		}
+	}
+
+	// Remove commas which are only followed by whitespace and a closing bracket
+	inString = false
+	for i := 0; i < len(out); i++ {
+		c := out[i]
+
+		if inString {
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+
+			continue
+		}
+
+		if c == '"' {
+			inString = true
+			continue
+		} else if c != ',' {
+			continue
+		}
+
+		next := i + 1
+		for next < len(out) && strings.ContainsRune(" \t\r\n", rune(out[next])) {
+			next++
+		}
+
+		if next < len(out) && (out[next] == '}' || out[next] == ']') {
+			out[i] = ' '
+		}
+	}
+
+	return out
+}
+
+// Returns true if haystack contains needle.
+func contains(haystack []string, needle string) bool {
+	for _, s := range haystack {
+		if s == needle {
+			return true
+		}
+	}
+
+	return false
+}
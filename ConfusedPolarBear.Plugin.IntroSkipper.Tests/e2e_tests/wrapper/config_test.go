@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestSampleConfiguration(t *testing.T) {
+	raw, err := os.ReadFile("../config_sample.jsonc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := parseConfiguration(raw, nil)
+	if err != nil {
+		t.Fatalf("sample configuration is invalid: %s", err)
+	}
+
+	// Three servers and eight servers expanded from the matrix
+	if len(config.Servers) != 11 || config.Servers[0].Browsers[1] != "firefox" {
+		t.Errorf("sample configuration was parsed incorrectly: %+v", config)
+	}
+}
+
+func TestStripJSONC(t *testing.T) {
+	raw := `{
+    // line comment
+    "url": "http://example.com/*not a comment*/", /* block
+    comment */ "list": [1, 2,],
+    "quote": "\"//\"",
+}`
+
+	var parsed map[string]interface{}
+	if err := json.Unmarshal(stripJSONC([]byte(raw)), &parsed); err != nil {
+		t.Fatalf("stripped JSONC is invalid: %s", err)
+	}
+
+	if parsed["url"] != "http://example.com/*not a comment*/" || parsed["quote"] != `"//"` {
+		t.Errorf("strings were modified: %v", parsed)
+	}
+
+	if list := parsed["list"].([]interface{}); len(list) != 2 {
+		t.Errorf("trailing comma was parsed incorrectly: %v", list)
+	}
+
+	if stripped := stripJSONC([]byte(raw)); len(stripped) != len(raw) || strings.Count(string(stripped), "\n") != 5 {
+		t.Error("stripping comments should preserve offsets and line numbers")
+	}
+}
+
+func TestConfigurationErrors(t *testing.T) {
+	raw := `{
+    "common": {"library": "/tv", "runtime": "lxc", "readiness": {"timeout": "-1m", "backoff": 0.5}},
+    "servers": [
+        {"address": "http://127.0.0.1:8096", "checkpoints": [{"description": " ", "timeout": "soon"}]},
+        {"image": "jellyfin/jellyfin", "browsers": ["chrome", "edge"], "tests": ["skip_buton"]},
+        {"comment": "no address"}
+    ]
+}`
+
+	_, err := parseConfiguration([]byte(raw), nil)
+	if err == nil {
+		t.Fatal("invalid configuration was accepted")
+	}
+
+	for _, expected := range []string{
+		`common.runtime: unsupported container runtime "lxc"`,
+		`common.readiness.timeout: must be positive`,
+		`common.readiness.backoff: must be at least 1`,
+		`servers[1].browsers[1]: unknown browser "edge"`,
+		`servers[1].tests[0]: unknown test "skip_buton"`,
+		`servers[0].checkpoints[0].description: a description of what to check is required`,
+		`servers[0].checkpoints[0].timeout: invalid duration "soon"`,
+		`servers[2]: either address or image is required`,
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("error does not contain %q:\n%s", expected, err)
+		}
+	}
+
+	_, err = parseConfiguration([]byte(`{"servers": [{"address": "a", "baseline": "latest"}]}`), nil)
+	if err == nil || !strings.Contains(err.Error(), "servers[0].baseline: a comment is required") {
+		t.Errorf("baseline without a comment was not reported: %v", err)
+	}
+
+	_, err = parseConfiguration([]byte(`{"servers": [{"address": "a", "comment": "same"}, {"address": "b", "comment": "same"}]}`), nil)
+	if err == nil || err.Error() != `servers[1].comment: "same" is already used by servers[0]` {
+		t.Errorf("duplicate comment was not reported: %v", err)
+	}
+
+	_, err = parseConfiguration([]byte(`{"servers": [{"address": "a"}, {"address": "b", "browser": "chrome"}]}`), nil)
+	if err == nil || err.Error() != "servers[1].browser: unknown field" {
+		t.Errorf("unknown field was not reported correctly: %v", err)
+	}
+
+	_, err = parseConfiguration([]byte("{\n  \"servers\": [\n    {\"address\": 1}\n  ]\n}"), nil)
+	if err == nil || !strings.HasPrefix(err.Error(), "line 3, column") {
+		t.Errorf("type error does not include the line number: %v", err)
+	}
+}
+
+// Ensures that the published JSON schema describes every configuration field.
+func TestConfigurationSchema(t *testing.T) {
+	raw, err := os.ReadFile("../config.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type object struct {
+		Properties map[string]json.RawMessage
+	}
+
+	var schema struct {
+		object
+		Definitions map[string]object
+	}
+
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(name string, schemaObject object, typ reflect.Type) {
+		var expected, actual []string
+		for field := range jsonFields(typ) {
+			expected = append(expected, field)
+		}
+
+		for property := range schemaObject.Properties {
+			actual = append(actual, property)
+		}
+
+		sort.Strings(expected)
+		sort.Strings(actual)
+
+		if !reflect.DeepEqual(expected, actual) {
+			t.Errorf("schema for %s has properties %v, expected %v", name, actual, expected)
+		}
+	}
+
+	check("configuration", schema.object, reflect.TypeOf(Configuration{}))
+	check("common", schema.Definitions["common"], reflect.TypeOf(Common{}))
+	check("server", schema.Definitions["server"], reflect.TypeOf(Server{}))
+	check("thresholds", schema.Definitions["thresholds"], reflect.TypeOf(Thresholds{}))
+	check("matrix", schema.Definitions["matrix"], reflect.TypeOf(Matrix{}))
+	check("library", schema.Definitions["library"], reflect.TypeOf(Library{}))
+	check("checkpoint", schema.Definitions["checkpoint"], reflect.TypeOf(Checkpoint{}))
+	check("readiness", schema.Definitions["readiness"], reflect.TypeOf(Readiness{}))
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// Prefix of the names of all containers and configuration directories used to test local Jellyfin servers.
+const containerName = "jf-e2e"
+
+// Label added to all containers used to test local Jellyfin servers, so that they can be found by -cleanup.
+const containerLabel = "io.github.intro-skipper.e2e"
+
+// Directory to create temporary container configuration directories in.
+var configurationRoot = "/dev/shm"
+
+// Creates a temporary configuration directory for a local server, installs the plugin DLL at plugin (if set) into it
+// and starts the server's container. The configuration directory is returned even if an error occurs so that it can be
+// cleaned up.
+func startContainer(log *Logger, runtime ContainerRuntime, server Server, libraries []Library, plugin string) (string, error) {
+	// Setup a temporary folder for the container's configuration
+	configurationDirectory, err := os.MkdirTemp(configurationRoot, containerName+"-*")
+	if err != nil {
+		return "", err
+	}
+
+	// Servers which generate EDL files need to write next to the media
+	if edlEnabled(server) {
+		writable := writableLibraries(libraries, configurationDirectory)
+		if err := copyLibraries(log, libraries, writable); err != nil {
+			return configurationDirectory, err
+		}
+
+		libraries = writable
+	}
+
+	// The plugin may instead be installed once the server is running
+	if plugin != "" {
+		if err := installPlugin(log, runtime, server, configurationDirectory, plugin); err != nil {
+			return configurationDirectory, err
+		}
+		log.Println()
+	}
+
+	log.Infof("Starting container %s with %s", server.Image, runtime.Name())
+	return configurationDirectory, runtime.Run(containerSpec(server, configurationDirectory, libraries))
+}
+
+// Returns the settings used to start a local server's container.
+func containerSpec(server Server, configurationDirectory string, libraries []Library) ContainerSpec {
+	/* Start the container with the following settings:
+	 *    Name:  unique name allocated for this server
+	 *    Port:  unique host port allocated for this server
+	 *    Media: Every library path mounted under /media, read only unless the server generates EDL files
+	 */
+	spec := ContainerSpec{
+		Name:    server.ContainerName,
+		Image:   server.Image,
+		Labels:  map[string]string{containerLabel: "true"},
+		Ports:   []PortMapping{{Host: server.Port, Container: 8096}},
+		Volumes: []VolumeMount{{Source: configurationDirectory, Target: "/config"}},
+	}
+
+	spec.Volumes = append(spec.Volumes, libraryMounts(libraries)...)
+	return spec
+}
+
+// Returns the directory that the plugin is installed to in a local server's configuration directory.
+func pluginDirectory(server Server, configurationDirectory string) string {
+	// LSIO containers use some slighly different paths & permissions
+	if isLSIOImage(server.Image) {
+		return path.Join(configurationDirectory, "data", "plugins", "intro-skipper")
+	}
+
+	return path.Join(configurationDirectory, "plugins", "intro-skipper")
+}
+
+// Returns true if the image is a LinuxServer.io image.
+func isLSIOImage(image string) bool {
+	return strings.Contains(image, "linuxserver")
+}
+
+// Installs the plugin DLL at plugin into a local server's configuration directory, replacing any previously
+// installed version of the plugin.
+func installPlugin(log *Logger, runtime ContainerRuntime, server Server, configurationDirectory, plugin string) error {
+	pluginDirectory := pluginDirectory(server, configurationDirectory)
+
+	// Remove any previous version so that only one copy of the plugin is loaded
+	if err := os.RemoveAll(pluginDirectory); err != nil {
+		return fmt.Errorf("failed to remove previous plugin: %w", err)
+	}
+
+	log.Infof("Creating plugin directory")
+	if err := os.MkdirAll(pluginDirectory, 0700); err != nil {
+		return fmt.Errorf("failed to create plugin directory: %w", err)
+	}
+
+	// Install the plugin
+	log.Infof("Copying plugin %s to %s", plugin, pluginDirectory)
+	if err := copyFile(plugin, path.Join(pluginDirectory, path.Base(plugin))); err != nil {
+		return fmt.Errorf("failed to install plugin: %w", err)
+	}
+
+	// If this is an LSIO container, adjust the permissions on the plugin directory
+	if isLSIOImage(server.Image) {
+		return runtime.Chown("911:911", path.Dir(pluginDirectory))
+	}
+
+	return nil
+}
+
+// Stops a local server's container and deletes its configuration directory.
+func stopContainer(log *Logger, runtime ContainerRuntime, name, configurationDirectory string) error {
+	log.Infof("Stopping and removing container")
+	stopErr := runtime.Stop(name)
+
+	// Cleanup the container's configuration
+	if configurationDirectory != "" {
+		log.Infof("Deleting %s", configurationDirectory)
+		if err := os.RemoveAll(configurationDirectory); err != nil {
+			return err
+		}
+	}
+
+	return stopErr
+}
+
+// Copies the file at source to destination.
+func copyFile(source, destination string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(destination, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
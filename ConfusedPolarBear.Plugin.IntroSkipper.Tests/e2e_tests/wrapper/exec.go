@@ -3,62 +3,251 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os/exec"
-	"regexp"
 	"strings"
+	"sync"
 	"time"
 )
 
-// Run an external program
-func RunProgram(program string, args []string, timeout time.Duration) {
-	// Flag if we are starting or stopping a container
-	managingContainer := program == "docker"
+// How long to keep reading output after a program was killed.
+const pipeGracePeriod = time.Second
+
+// Options used when running an external program.
+type ProgramOptions struct {
+	// Logger to stream the program's output to.
+	Log *Logger
+
+	// Kills the program once canceled. Defaults to the run context, which is canceled when the wrapper is interrupted.
+	Context context.Context
+
+	// Working directory. Defaults to the current directory.
+	Dir string
+
+	// Maximum amount of time the program is allowed to run for before it is killed.
+	Timeout time.Duration
+
+	// If set, also receives a copy of every line of output.
+	Tee io.Writer
+
+	// Capture the program's output without streaming it to the log.
+	Quiet bool
+}
+
+// Result of running an external program.
+type ProgramResult struct {
+	// Exit code of the program. -1 if the program could not be started or was killed.
+	ExitCode int
+
+	// How long the program ran for.
+	Duration time.Duration
+
+	// Captured standard output and standard error.
+	Stdout string
+	Stderr string
+
+	// Captured standard output and standard error, interleaved in the order it was received.
+	Output string
+
+	// Set if the program was killed because it exceeded its timeout.
+	TimedOut bool
+
+	// Set if the program could not be started, exited with a non-zero status or timed out.
+	Err error
+}
+
+// Returns true if the program exited successfully.
+func (r ProgramResult) Success() bool {
+	return r.Err == nil
+}
+
+// Run an external program to completion. Standard output and standard error are streamed concurrently to the
+// log, with each line marked with the stream it came from, and captured in the returned result.
+func RunProgram(program string, args []string, opts ProgramOptions) ProgramResult {
+	var result ProgramResult
+
+	if opts.Log == nil {
+		opts.Log = rootLog
+	}
+
+	if opts.Context == nil {
+		opts.Context = runContext
+	}
 
 	// Create context and command
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	ctx, cancel := context.WithTimeout(opts.Context, opts.Timeout)
 	defer cancel()
-	cmd := exec.CommandContext(ctx, program, args...)
+	cmd := exec.Command(program, args...)
+	cmd.Dir = opts.Dir
+	setProcessGroup(cmd)
 
 	// Stringify and censor the program's arguments
-	strArgs := redactString(strings.Join(args, " "))
-	fmt.Printf("  [+] Running %s %s\n", program, strArgs)
+	strArgs := redact(strings.Join(args, " "))
+	opts.Log.Infof("Running %s %s", program, strArgs)
+
+	fail := func(err error) ProgramResult {
+		result.ExitCode = -1
+		result.Err = fmt.Errorf("%s: %w", program, err)
+		return result
+	}
 
 	// Setup pipes
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		panic(err)
+		return fail(err)
 	}
 
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
-		panic(err)
+		return fail(err)
 	}
 
 	// Start the command
+	start := time.Now()
 	if err := cmd.Start(); err != nil {
-		panic(err)
+		return fail(err)
 	}
 
-	// Stream any messages to the terminal
-	for _, r := range []io.Reader{stdout, stderr} {
-		// Don't log stdout from the container
-		if managingContainer && r == stdout {
-			continue
+	// Stream both pipes at the same time so that neither can fill up and block the program
+	var stdoutBuf, stderrBuf, combined strings.Builder
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+
+	stream := func(r io.Reader, buf *strings.Builder, name string) {
+		defer wg.Done()
+
+		reader := bufio.NewReader(r)
+		for {
+			line, err := reader.ReadString('\n')
+			if line != "" {
+				lock.Lock()
+				buf.WriteString(line)
+				combined.WriteString(line)
+
+				if opts.Tee != nil {
+					io.WriteString(opts.Tee, redact(line))
+				}
+				lock.Unlock()
+
+				if !opts.Quiet {
+					opts.Log.Output(name, strings.TrimSuffix(line, "\n"))
+				}
+			}
+
+			if err != nil {
+				return
+			}
 		}
+	}
+
+	wg.Add(2)
+	go stream(stdout, &stdoutBuf, "stdout")
+	go stream(stderr, &stderrBuf, "stderr")
 
-		scanner := bufio.NewScanner(r)
-		scanner.Split(bufio.ScanRunes)
+	// All output must be read before waiting for the program to exit
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
 
-		for scanner.Scan() {
-			fmt.Print(scanner.Text())
+	select {
+	case <-done:
+
+	case <-ctx.Done():
+		killProcessGroup(cmd)
+
+		// Processes which left the group may still hold the pipes open, so stop reading after a grace period
+		select {
+		case <-done:
+		case <-time.After(pipeGracePeriod):
+			stdout.Close()
+			stderr.Close()
+			<-done
 		}
 	}
+
+	waitErr := cmd.Wait()
+
+	result.Duration = time.Since(start)
+	result.Stdout = stdoutBuf.String()
+	result.Stderr = stderrBuf.String()
+	result.Output = combined.String()
+	result.ExitCode = cmd.ProcessState.ExitCode()
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		result.TimedOut = true
+		result.Err = fmt.Errorf("%s timed out after %s", program, opts.Timeout)
+	} else if errors.Is(ctx.Err(), context.Canceled) {
+		result.Err = fmt.Errorf("%s was interrupted", program)
+	} else if waitErr != nil {
+		result.Err = fmt.Errorf("%s failed: %w", program, waitErr)
+	}
+
+	if result.Err != nil {
+		opts.Log.Errorf("%s", result.Err)
+	}
+
+	return result
 }
 
-// Redacts sensitive command line arguments.
-func redactString(raw string) string {
-	redactionRegex := regexp.MustCompilePOSIX(`-(user|pass|key) [^ ]+`)
-	return redactionRegex.ReplaceAllString(raw, "-$1 REDACTED")
+// Run an external program to completion without logging its output and return its standard output. An error is
+// returned if the program could not be started, exited with a non-zero status or did not exit before the timeout.
+func CaptureProgram(program string, args []string, opts ProgramOptions) (string, error) {
+	opts.Quiet = true
+	result := RunProgram(program, args, opts)
+
+	if result.Err != nil && result.Stderr != "" {
+		return result.Stdout, fmt.Errorf("%w: %s", result.Err, strings.TrimSpace(result.Stderr))
+	}
+
+	return result.Stdout, result.Err
+}
+
+// An external program, the arguments to run it with and the directory to run it in.
+type Command struct {
+	Program string
+	Args    []string
+	Dir     string
+
+	// Describes when the command is run, if not always. Only used when printing the plan of a dry run.
+	Note string
+}
+
+// Runs the command to completion. See RunProgram.
+func (c Command) Run(opts ProgramOptions) ProgramResult {
+	opts.Dir = c.Dir
+	return RunProgram(c.Program, c.Args, opts)
+}
+
+// Runs the command to completion without logging its output. See CaptureProgram.
+func (c Command) Capture(opts ProgramOptions) (string, error) {
+	opts.Dir = c.Dir
+	return CaptureProgram(c.Program, c.Args, opts)
+}
+
+// Returns the command line with all credentials redacted. Arguments are quoted if needed.
+func (c Command) String() string {
+	words := []string{c.Program}
+	for _, arg := range c.Args {
+		words = append(words, shellQuote(arg))
+	}
+
+	line := redact(strings.Join(words, " "))
+	if c.Dir != "" {
+		line = fmt.Sprintf("(cd %s && %s)", c.Dir, line)
+	}
+
+	return line
+}
+
+// Quotes an argument with single quotes if it is empty or contains whitespace or shell metacharacters.
+func shellQuote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\n'\"\\$`{}*?|&;<>()[]#~") {
+		return arg
+	}
+
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
 }
@@ -1,13 +1,135 @@
 package main
 
-import "testing"
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
 
-func TestStringRedaction(t *testing.T) {
-	raw := "-key deadbeef -first second -user admin -third fourth -pass hunter2"
-	expected := "-key REDACTED -first second -user REDACTED -third fourth -pass REDACTED"
-	actual := redactString(raw)
+func TestRunProgramExitCode(t *testing.T) {
+	captureLog(t)
 
-	if expected != actual {
-		t.Errorf(`String was redacted incorrectly: "%s"`, actual)
+	result := RunProgram("sh", []string{"-c", "echo out; echo err >&2; exit 3"}, ProgramOptions{Timeout: 5 * time.Second})
+
+	if result.Success() || result.ExitCode != 3 {
+		t.Errorf("expected exit code 3, found %d (%v)", result.ExitCode, result.Err)
+	}
+
+	if result.Stdout != "out\n" || result.Stderr != "err\n" {
+		t.Errorf("output was captured incorrectly: %q, %q", result.Stdout, result.Stderr)
+	}
+
+	if result.TimedOut {
+		t.Error("program should not have timed out")
+	}
+}
+
+func TestRunProgramTimeout(t *testing.T) {
+	captureLog(t)
+
+	result := RunProgram("sleep", []string{"5"}, ProgramOptions{Timeout: 100 * time.Millisecond})
+
+	if !result.TimedOut || result.Success() {
+		t.Errorf("expected program to time out: %+v", result)
+	}
+
+	if result.Duration >= 5*time.Second {
+		t.Errorf("program was not killed after timing out: %s", result.Duration)
+	}
+}
+
+func TestRunProgramStreaming(t *testing.T) {
+	buf := captureLog(t)
+	var tee bytes.Buffer
+
+	// Write more than a pipe buffer to stderr before stdout is closed to ensure that both pipes are read concurrently
+	script := "head -c 200000 /dev/zero | tr '\\0' 'a' >&2; echo; echo done"
+	result := RunProgram("sh", []string{"-c", script}, ProgramOptions{
+		Log:     newLogger("test"),
+		Timeout: 5 * time.Second,
+		Tee:     &tee,
+	})
+
+	if !result.Success() {
+		t.Fatalf("program failed: %v", result.Err)
+	}
+
+	if !strings.Contains(buf.String(), "[test]     | done\n") || !strings.Contains(buf.String(), "[test]     ! aaaa") {
+		t.Errorf("output lines were not prefixed: %q", buf.String()[:100])
+	}
+
+	if tee.String() != result.Output {
+		t.Error("tee'd output does not match captured output")
+	}
+}
+
+func TestRunProgramTimeoutWithChildren(t *testing.T) {
+	captureLog(t)
+
+	// Background processes inherit the pipes and keep them open after the shell is killed
+	script := "sleep 5 & echo started; wait"
+	if _, err := exec.LookPath("setsid"); err == nil {
+		// Also start a process which leaves the process group
+		script = "setsid sleep 5 & " + script
+	}
+
+	result := RunProgram("sh", []string{"-c", script}, ProgramOptions{Timeout: 200 * time.Millisecond})
+
+	if !result.TimedOut {
+		t.Errorf("expected program to time out: %+v", result)
+	}
+
+	if result.Duration >= 3*time.Second {
+		t.Errorf("waited for child processes after timing out: %s", result.Duration)
+	}
+}
+
+func TestRunProgramMissing(t *testing.T) {
+	captureLog(t)
+
+	result := RunProgram("/nonexistent/program", nil, ProgramOptions{Timeout: time.Second})
+	if result.Success() || result.ExitCode != -1 {
+		t.Errorf("expected missing program to fail: %+v", result)
+	}
+}
+
+func TestRunProgramInterrupted(t *testing.T) {
+	captureLog(t)
+	ctx := interruptTest(t)
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		ctx()
+	}()
+
+	result := RunProgram("sleep", []string{"5"}, ProgramOptions{Timeout: 10 * time.Second})
+	if result.Success() || result.TimedOut || !strings.Contains(result.Err.Error(), "interrupted") {
+		t.Errorf("expected program to be interrupted: %+v", result)
+	}
+
+	if result.Duration >= 5*time.Second {
+		t.Errorf("program was not killed after an interrupt: %s", result.Duration)
+	}
+
+	// Cleanup commands are run with their own context
+	result = RunProgram("true", nil, ProgramOptions{Context: context.Background(), Timeout: 5 * time.Second})
+	if !result.Success() {
+		t.Errorf("detached program failed after an interrupt: %v", result.Err)
+	}
+}
+
+func TestCommandString(t *testing.T) {
+	command := Command{
+		Program: "python3",
+		Args:    []string{"main.py", "-pass", "hunter2", "-name", "Pilot's Return", "--format", "{{.CPUPerc}}", ""},
+		Dir:     "selenium",
+	}
+
+	expected := `(cd selenium && python3 main.py -pass REDACTED -name 'Pilot'\''s Return' --format '{{.CPUPerc}}' '')`
+	if actual := command.String(); actual != expected {
+		t.Errorf("command was formatted incorrectly: %s", actual)
 	}
 }
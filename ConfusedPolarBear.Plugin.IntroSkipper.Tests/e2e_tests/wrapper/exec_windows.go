@@ -0,0 +1,13 @@
+package main
+
+import "os/exec"
+
+// Process groups are not supported on Windows.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// Kills the program. Processes it started are not killed.
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		cmd.Process.Kill()
+	}
+}
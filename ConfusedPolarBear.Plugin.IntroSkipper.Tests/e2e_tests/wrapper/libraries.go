@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Collection types which can be used for libraries.
+var supportedCollectionTypes = []string{"tvshows", "movies", "mixed"}
+
+// Returns all libraries to create on local servers, along with the host paths to mount for them. If no libraries
+// are configured, a single "Shows" library is created from the legacy library setting.
+func resolveLibraries(common Common) []Library {
+	if len(common.Libraries) == 0 {
+		if common.Library == "" {
+			return nil
+		}
+
+		// The legacy library directory is mounted at /media and the library is created from its TV folder
+		return []Library{{
+			Name:           "Shows",
+			CollectionType: "tvshows",
+			Paths:          []string{common.Library},
+			Mounts:         []VolumeMount{{Source: common.Library, Target: "/media", ReadOnly: true}},
+			ContainerPaths: []string{"/media/TV"},
+		}}
+	}
+
+	var libraries []Library
+	for i, library := range common.Libraries {
+		library.Mounts = nil
+		library.ContainerPaths = nil
+
+		for j, source := range library.Paths {
+			target := fmt.Sprintf("/media/library-%d-%d", i, j)
+			library.Mounts = append(library.Mounts, VolumeMount{Source: source, Target: target, ReadOnly: true})
+			library.ContainerPaths = append(library.ContainerPaths, target)
+		}
+
+		libraries = append(libraries, library)
+	}
+
+	return libraries
+}
+
+// Returns true if the server's plugin settings enable EDL generation.
+func edlEnabled(server Server) bool {
+	switch action := server.PluginConfiguration["EdlAction"].(type) {
+	case nil:
+		return false
+
+	case string:
+		return action != "" && action != "None"
+
+	case float64:
+		return action != 0
+
+	default:
+		return true
+	}
+}
+
+// Returns the libraries with every mount replaced by a writable copy. EDL files are written next to the media, so
+// servers which generate them cannot use the read only library paths. Each copy is created next to the library path
+// it was made from and named after the configuration directory, so that media files can be hard linked into it
+// instead of being copied into the configuration directory's tmpfs.
+func writableLibraries(libraries []Library, configurationDirectory string) []Library {
+	var writable []Library
+	count := 0
+
+	for _, library := range libraries {
+		mounts := make([]VolumeMount, len(library.Mounts))
+		for i, mount := range library.Mounts {
+			name := fmt.Sprintf(".%s-%d", path.Base(configurationDirectory), count)
+			mount.Source = path.Join(path.Dir(mount.Source), name)
+			mount.ReadOnly = false
+			mounts[i] = mount
+			count++
+		}
+
+		library.Mounts = mounts
+		writable = append(writable, library)
+	}
+
+	return writable
+}
+
+// Creates the writable copies of every library path returned by writableLibraries. Media files are hard linked when
+// possible and copied otherwise. Existing EDL files are not copied, so every EDL file in a copy was written by the
+// server.
+func copyLibraries(log *Logger, libraries, writable []Library) error {
+	for i, library := range libraries {
+		for j, mount := range library.Mounts {
+			copied := writable[i].Mounts[j].Source
+			log.Infof("Creating writable copy of %s in %s", mount.Source, copied)
+
+			linked, err := linkTree(mount.Source, copied)
+			if err != nil {
+				return fmt.Errorf("failed to copy library %s: %w", mount.Source, err)
+			}
+
+			if !linked {
+				log.Warnf("Unable to hard link every file in %s, some files were copied instead", mount.Source)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Recreates the directory tree at source in destination, hard linking or copying every file except EDL files. Returns
+// false if any file had to be copied.
+//
+// Hard linked files share their owner and permissions with the original media, so they are never changed. Instead,
+// every directory is made writable by everyone, which allows servers running as any user (such as LSIO images) to
+// write EDL files, and copied files are made readable by everyone.
+func linkTree(source, destination string) (bool, error) {
+	linked := true
+
+	err := filepath.WalkDir(source, func(current string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relative, err := filepath.Rel(source, current)
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(destination, relative)
+
+		switch {
+		case entry.IsDir():
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+
+			// Not affected by the umask, unlike the mode passed to MkdirAll
+			return os.Chmod(target, 0777)
+
+		case strings.EqualFold(filepath.Ext(current), ".edl"):
+			return nil
+
+		case !entry.Type().IsRegular():
+			return nil
+		}
+
+		if err := os.Link(current, target); err == nil {
+			return nil
+		}
+
+		linked = false
+		if err := copyFile(current, target); err != nil {
+			return err
+		}
+
+		return os.Chmod(target, 0644)
+	})
+
+	return linked, err
+}
+
+// Deletes the writable copies of a local server's libraries, if any were created.
+func removeWritableLibraries(log *Logger, server Server, libraries []Library, configurationDirectory string) error {
+	if !edlEnabled(server) || configurationDirectory == "" {
+		return nil
+	}
+
+	for _, mount := range libraryMounts(writableLibraries(libraries, configurationDirectory)) {
+		if _, err := os.Stat(mount.Source); err != nil {
+			continue
+		}
+
+		log.Infof("Deleting %s", mount.Source)
+		if err := os.RemoveAll(mount.Source); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Returns the volumes to mount for all libraries.
+func libraryMounts(libraries []Library) []VolumeMount {
+	var mounts []VolumeMount
+	for _, library := range libraries {
+		mounts = append(mounts, library.Mounts...)
+	}
+
+	return mounts
+}
+
+// Returns the URL path and request body used to create a library with the VirtualFolders API.
+func libraryRequest(library Library) (string, string, error) {
+	var payload map[string]interface{}
+	if err := json.Unmarshal([]byte(librarySetupPayload), &payload); err != nil {
+		return "", "", err
+	}
+
+	options, ok := payload["LibraryOptions"].(map[string]interface{})
+	if !ok {
+		return "", "", fmt.Errorf("library payload does not contain LibraryOptions")
+	}
+
+	var pathInfos []map[string]string
+	for _, containerPath := range library.ContainerPaths {
+		pathInfos = append(pathInfos, map[string]string{"Path": containerPath})
+	}
+	options["PathInfos"] = pathInfos
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", "", err
+	}
+
+	query := url.Values{}
+	query.Set("name", library.Name)
+	query.Set("refreshLibrary", "false")
+	if library.CollectionType != "mixed" {
+		query.Set("collectionType", library.CollectionType)
+	}
+
+	return "Library/VirtualFolders?" + query.Encode(), string(body), nil
+}
+
+// Checks that all libraries have a unique name, a supported collection type and absolute host paths.
+func validateLibraries(libraries []Library) configurationErrors {
+	var problems configurationErrors
+
+	add := func(field, format string, args ...interface{}) {
+		problems = append(problems, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+
+	names := make(map[string]bool)
+	for i, library := range libraries {
+		prefix := fmt.Sprintf("common.libraries[%d]", i)
+
+		if library.Name == "" {
+			add(prefix+".name", "is required")
+		} else if names[library.Name] {
+			add(prefix+".name", "duplicate library name %q", library.Name)
+		}
+		names[library.Name] = true
+
+		if !contains(supportedCollectionTypes, library.CollectionType) {
+			add(prefix+".collection_type", "unknown collection type %q (expected one of tvshows, movies, mixed)",
+				library.CollectionType)
+		}
+
+		if len(library.Paths) == 0 {
+			add(prefix+".paths", "at least one path is required")
+		}
+
+		for j, source := range library.Paths {
+			if !filepath.IsAbs(source) {
+				add(fmt.Sprintf("%s.paths[%d]", prefix, j), "must be an absolute path")
+			}
+		}
+	}
+
+	return problems
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestLegacyLibrary(t *testing.T) {
+	libraries := resolveLibraries(Common{Library: "/srv/TV"})
+	if len(libraries) != 1 {
+		t.Fatalf("expected one library, found %v", libraries)
+	}
+
+	mounts := libraryMounts(libraries)
+	if len(mounts) != 1 || mounts[0].Source != "/srv/TV" || mounts[0].Target != "/media" || !mounts[0].ReadOnly {
+		t.Errorf("legacy library was mounted incorrectly: %+v", mounts)
+	}
+
+	endpoint, _, err := libraryRequest(libraries[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if endpoint != "Library/VirtualFolders?collectionType=tvshows&name=Shows&refreshLibrary=false" {
+		t.Errorf("incorrect endpoint: %s", endpoint)
+	}
+}
+
+func TestLibraries(t *testing.T) {
+	libraries := resolveLibraries(Common{
+		Library: "/ignored",
+		Libraries: []Library{
+			{Name: "Shows", CollectionType: "tvshows", Paths: []string{"/srv/TV", "/srv/Anime"}},
+			{Name: "Home Movies", CollectionType: "mixed", Paths: []string{"/srv/Movies"}},
+		},
+	})
+
+	mounts := libraryMounts(libraries)
+	if len(mounts) != 3 || mounts[1].Source != "/srv/Anime" || mounts[1].Target != "/media/library-0-1" {
+		t.Errorf("libraries were mounted incorrectly: %+v", mounts)
+	}
+
+	for _, mount := range mounts {
+		if !mount.ReadOnly || mount.Source == "/ignored" {
+			t.Errorf("incorrect mount: %+v", mount)
+		}
+	}
+
+	endpoint, body, err := libraryRequest(libraries[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query, err := url.ParseQuery(strings.SplitN(endpoint, "?", 2)[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Mixed libraries must not set a collection type
+	if query.Get("name") != "Home Movies" || query.Has("collectionType") {
+		t.Errorf("incorrect endpoint: %s", endpoint)
+	}
+
+	var payload struct {
+		LibraryOptions struct {
+			EnableRealtimeMonitor bool
+			PathInfos             []struct{ Path string }
+		}
+	}
+
+	if err := json.Unmarshal([]byte(body), &payload); err != nil {
+		t.Fatal(err)
+	}
+
+	paths := payload.LibraryOptions.PathInfos
+	if len(paths) != 1 || paths[0].Path != "/media/library-1-0" {
+		t.Errorf("incorrect paths in payload: %+v", paths)
+	}
+}
+
+func TestInvalidLibraries(t *testing.T) {
+	raw := `{
+    "common": {"libraries": [
+        {"name": "Shows", "collection_type": "tvshows", "paths": ["relative/TV"]},
+        {"name": "Shows", "collection_type": "music", "paths": []}
+    ]},
+    "servers": [{"image": "jellyfin/jellyfin"}]
+}`
+
+	_, err := parseConfiguration([]byte(raw), nil)
+	if err == nil {
+		t.Fatal("invalid libraries were accepted")
+	}
+
+	for _, expected := range []string{
+		"common.libraries[0].paths[0]: must be an absolute path",
+		`common.libraries[1].name: duplicate library name "Shows"`,
+		`common.libraries[1].collection_type: unknown collection type "music"`,
+		"common.libraries[1].paths: at least one path is required",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("error does not contain %q:\n%s", expected, err)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestStartContainerLinkedLibrary(t *testing.T) {
+	captureLog(t)
+	setupContainerTest(t)
+
+	library := t.TempDir()
+	episode := filepath.Join(library, "TV", "Show", "S01E01.mkv")
+	writeTestFile(t, episode, "video")
+
+	before, err := os.Stat(episode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runtime := newFakeRuntime()
+	server := Server{
+		Image:               "lscr.io/linuxserver/jellyfin",
+		ContainerName:       "jf-e2e-1",
+		PluginConfiguration: map[string]interface{}{"EdlAction": "Intro"},
+	}
+
+	libraries := resolveLibraries(Common{Library: library})
+	directory, err := startContainer(rootLog, runtime, server, libraries, pluginPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The media must be hard linked into a copy next to the library, never into the configuration directory
+	copied := writableLibraries(libraries, directory)[0].Mounts[0].Source
+	linked, err := os.Stat(filepath.Join(copied, "TV", "Show", "S01E01.mkv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !os.SameFile(before, linked) {
+		t.Error("media was copied instead of hard linked")
+	}
+
+	// Only the plugin directory may be chowned, as chowning a hard link changes the owner of the original media
+	for _, call := range runtime.Calls {
+		if strings.HasPrefix(call, "chown") && strings.Contains(call, copied) {
+			t.Errorf("library copy was chowned: %s", call)
+		}
+	}
+
+	after, err := os.Stat(episode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	owner := func(info os.FileInfo) [2]uint32 {
+		stat := info.Sys().(*syscall.Stat_t)
+		return [2]uint32{stat.Uid, stat.Gid}
+	}
+
+	if !os.SameFile(before, after) || owner(before) != owner(after) || before.Mode() != after.Mode() {
+		t.Errorf("original media was changed: %v %v, %v %v", owner(before), before.Mode(), owner(after), after.Mode())
+	}
+
+	// Directories are writable by the server instead
+	info, err := os.Stat(filepath.Join(copied, "TV", "Show"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode().Perm() != 0777 {
+		t.Errorf("library copy is not writable: %v", info.Mode())
+	}
+}
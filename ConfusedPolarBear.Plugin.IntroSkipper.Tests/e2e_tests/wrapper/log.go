@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Severity of a logged message.
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < 0 || int(l) >= len(levelNames) {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+
+	return levelNames[l]
+}
+
+// Parses a level name. Used as a flag.Value.
+func (l *Level) Set(name string) error {
+	for i, levelName := range levelNames {
+		if strings.EqualFold(name, levelName) {
+			*l = Level(i)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown log level %q, must be one of %s", name, strings.Join(levelNames, ", "))
+}
+
+// Markers shown before messages of each level in text output.
+var levelMarkers = []string{"[.]", "[+]", "[!]", "[!]"}
+
+// ANSI colors used for the marker of each level when color is enabled.
+var levelColors = []string{"\x1b[90m", "\x1b[32m", "\x1b[33m", "\x1b[31m"}
+
+const colorReset = "\x1b[0m"
+
+// Format of all logged output.
+type LogFormat string
+
+const (
+	// Human readable lines prefixed with the server name and a marker for the level.
+	FormatText LogFormat = "text"
+
+	// One JSON object per line, for parsing in CI.
+	FormatJSON LogFormat = "json"
+)
+
+func (f LogFormat) String() string {
+	return string(f)
+}
+
+// Parses a log format. Used as a flag.Value.
+func (f *LogFormat) Set(name string) error {
+	switch LogFormat(name) {
+	case FormatText, FormatJSON:
+		*f = LogFormat(name)
+		return nil
+	}
+
+	return fmt.Errorf("unknown log format %q, must be text or json", name)
+}
+
+// When to colorize text output.
+type ColorMode string
+
+const (
+	ColorAuto   ColorMode = "auto"
+	ColorAlways ColorMode = "always"
+	ColorNever  ColorMode = "never"
+)
+
+func (c ColorMode) String() string {
+	return string(c)
+}
+
+// Parses a color mode. Used as a flag.Value.
+func (c *ColorMode) Set(name string) error {
+	switch ColorMode(name) {
+	case ColorAuto, ColorAlways, ColorNever:
+		*c = ColorMode(name)
+		return nil
+	}
+
+	return fmt.Errorf("unknown color mode %q, must be auto, always or never", name)
+}
+
+// Logging options, set from the command line.
+var (
+	logLevel  = LevelInfo
+	logFormat = FormatText
+	logColor  = ColorAuto
+)
+
+// Set by configureLogging if text output is colorized.
+var colorEnabled bool
+
+// Destination of all logged output.
+var logOutput io.Writer = os.Stdout
+
+// Serializes writes to stdout so that lines from concurrently tested servers are not interleaved.
+var stdoutLock sync.Mutex
+
+// Resolves the color mode. In auto mode, output is only colorized if stdout is a terminal and NO_COLOR is unset.
+func configureLogging() {
+	switch logColor {
+	case ColorAlways:
+		colorEnabled = true
+
+	case ColorNever:
+		colorEnabled = false
+
+	default:
+		colorEnabled = isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb"
+	}
+}
+
+// Returns true if the file is a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Arguments which pass the logging options on to the verifier. Defaults are omitted.
+func loggingArguments() []string {
+	var args []string
+
+	if logFormat != FormatText {
+		args = append(args, "-log-format", logFormat.String())
+	}
+
+	if logLevel != LevelInfo {
+		args = append(args, "-log-level", logLevel.String())
+	}
+
+	return args
+}
+
+// A single logged message. In JSON output, every record is written as one line.
+type logRecord struct {
+	Time    string `json:"time"`
+	Level   string `json:"level"`
+	Server  string `json:"server,omitempty"`
+	Stage   string `json:"stage,omitempty"`
+	Stream  string `json:"stream,omitempty"`
+	Message string `json:"msg"`
+}
+
+// Writes messages to stdout, tagged with the server and stage they relate to.
+type Logger struct {
+	server string
+
+	// Stage which is currently running, if any.
+	stage string
+	mu    sync.Mutex
+
+	// Drop everything written to this logger.
+	discard bool
+}
+
+// Root logger for output that isn't related to a single server.
+var rootLog = &Logger{}
+
+// Logger for frequent polling which would otherwise flood the output.
+var discardLog = &Logger{discard: true}
+
+// Creates a logger which tags every message with the provided server name.
+func newLogger(server string) *Logger {
+	return &Logger{server: server}
+}
+
+// Tags all following messages with a stage. An empty name clears the stage.
+func (l *Logger) SetStage(stage string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stage = stage
+}
+
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	l.log(LevelDebug, "", true, fmt.Sprintf(format, args...))
+}
+
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.log(LevelInfo, "", true, fmt.Sprintf(format, args...))
+}
+
+func (l *Logger) Warnf(format string, args ...interface{}) {
+	l.log(LevelWarn, "", true, fmt.Sprintf(format, args...))
+}
+
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.log(LevelError, "", true, fmt.Sprintf(format, args...))
+}
+
+// Logs preformatted informational text, such as a table, without a level marker.
+func (l *Logger) Printf(format string, args ...interface{}) {
+	l.log(LevelInfo, "", false, fmt.Sprintf(format, args...))
+}
+
+// Logs preformatted informational text without a level marker. Without arguments, logs a blank line.
+func (l *Logger) Println(args ...interface{}) {
+	l.log(LevelInfo, "", false, fmt.Sprintln(args...))
+}
+
+// Logs a line of output from an external program. If the program logged a JSON record itself, the record is passed
+// through with this logger's server and stage added.
+func (l *Logger) Output(stream, line string) {
+	if logFormat == FormatJSON {
+		var record logRecord
+		if err := json.Unmarshal([]byte(line), &record); err == nil && record.Level != "" {
+			var level Level
+			if err := level.Set(record.Level); err != nil {
+				level = LevelInfo
+			}
+
+			l.log(level, stream, false, record.Message)
+			return
+		}
+	}
+
+	l.log(LevelInfo, stream, false, line)
+}
+
+// Writes every line in text with all secrets redacted, if its level is enabled. A trailing newline is ignored.
+func (l *Logger) log(level Level, stream string, marked bool, text string) {
+	if l.discard || level < logLevel {
+		return
+	}
+
+	l.mu.Lock()
+	record := logRecord{
+		Time:   time.Now().Format(time.RFC3339Nano),
+		Level:  level.String(),
+		Server: l.server,
+		Stage:  l.stage,
+		Stream: stream,
+	}
+	l.mu.Unlock()
+
+	var out strings.Builder
+
+	for _, line := range strings.SplitAfter(strings.TrimSuffix(redact(text), "\n"), "\n") {
+		record.Message = strings.TrimSuffix(line, "\n")
+
+		if logFormat == FormatJSON {
+			// Blank lines only separate sections of text output
+			if strings.TrimSpace(record.Message) == "" {
+				continue
+			}
+
+			raw, err := json.Marshal(record)
+			if err != nil {
+				panic(err)
+			}
+
+			out.Write(raw)
+			out.WriteString("\n")
+		} else {
+			out.WriteString(formatText(record, level, marked))
+		}
+	}
+
+	stdoutLock.Lock()
+	defer stdoutLock.Unlock()
+	io.WriteString(logOutput, out.String())
+}
+
+// Formats a record as a line of text. Messages logged during a stage are indented under it, and lines of program
+// output are marked with the stream they came from.
+func formatText(record logRecord, level Level, marked bool) string {
+	var line strings.Builder
+
+	if record.Server != "" {
+		line.WriteString("[" + record.Server + "]")
+		if record.Message != "" || marked || record.Stream != "" {
+			line.WriteString(" ")
+		}
+	}
+
+	switch {
+	case record.Stream == "stdout":
+		line.WriteString("    | ")
+
+	case record.Stream == "stderr":
+		line.WriteString("    ! ")
+
+	case marked:
+		if record.Stage != "" {
+			line.WriteString("  ")
+		}
+
+		marker := levelMarkers[level]
+		if colorEnabled {
+			marker = levelColors[level] + marker + colorReset
+		}
+
+		line.WriteString(marker + " ")
+	}
+
+	line.WriteString(record.Message)
+	line.WriteString("\n")
+
+	return line.String()
+}
+
+// Returns a writer that logs every complete line written to it. Call Flush to log any trailing partial line.
+func (l *Logger) Writer() *LineWriter {
+	return &LineWriter{log: l}
+}
+
+// Buffers written data and logs each complete line.
+type LineWriter struct {
+	log *Logger
+	buf bytes.Buffer
+	mu  sync.Mutex
+}
+
+func (w *LineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf.Write(p)
+
+	for {
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i < 0 {
+			break
+		}
+
+		line := string(w.buf.Next(i + 1))
+		w.log.Printf("%s", line)
+	}
+
+	return len(p), nil
+}
+
+// Logs any buffered partial line.
+func (w *LineWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.buf.Len() > 0 {
+		w.log.Printf("%s", w.buf.String())
+		w.buf.Reset()
+	}
+}
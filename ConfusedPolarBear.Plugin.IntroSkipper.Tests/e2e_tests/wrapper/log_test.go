@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// Captures all logged output until the test finishes.
+func captureLog(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+
+	old := logOutput
+	logOutput = &buf
+	t.Cleanup(func() { logOutput = old })
+
+	return &buf
+}
+
+// Sets the logging options until the test finishes.
+func setLogging(t *testing.T, level Level, format LogFormat, color bool) {
+	oldLevel, oldFormat, oldColor := logLevel, logFormat, colorEnabled
+	t.Cleanup(func() { logLevel, logFormat, colorEnabled = oldLevel, oldFormat, oldColor })
+
+	logLevel, logFormat, colorEnabled = level, format, color
+}
+
+func TestLoggerPrefix(t *testing.T) {
+	buf := captureLog(t)
+	log := newLogger("10.8.9")
+
+	log.Infof("Stage: setup")
+	log.SetStage("setup")
+	log.Infof("first\nsecond")
+	log.Println()
+	log.Printf("no newline")
+	log.Output("stdout", "child")
+
+	expected := "[10.8.9] [+] Stage: setup\n[10.8.9]   [+] first\n[10.8.9]   [+] second\n[10.8.9]\n" +
+		"[10.8.9] no newline\n[10.8.9]     | child\n"
+
+	if buf.String() != expected {
+		t.Errorf("output was prefixed incorrectly: %q", buf.String())
+	}
+}
+
+func TestLoggerLevels(t *testing.T) {
+	buf := captureLog(t)
+	setLogging(t, LevelWarn, FormatText, false)
+
+	rootLog.Debugf("debug")
+	rootLog.Infof("info")
+	rootLog.Warnf("warn")
+	rootLog.Errorf("error")
+
+	if expected := "[!] warn\n[!] error\n"; buf.String() != expected {
+		t.Errorf("levels were filtered incorrectly: %q", buf.String())
+	}
+
+	var level Level
+	if err := level.Set("DEBUG"); err != nil || level != LevelDebug {
+		t.Errorf("unable to parse level: %v", err)
+	}
+
+	if err := level.Set("verbose"); err == nil {
+		t.Error("unknown level was accepted")
+	}
+}
+
+func TestLoggerColor(t *testing.T) {
+	buf := captureLog(t)
+	setLogging(t, LevelInfo, FormatText, true)
+
+	rootLog.Errorf("failed")
+	rootLog.Printf("plain")
+
+	if expected := "\x1b[31m[!]\x1b[0m failed\nplain\n"; buf.String() != expected {
+		t.Errorf("output was colorized incorrectly: %q", buf.String())
+	}
+}
+
+func TestLoggerJSON(t *testing.T) {
+	buf := captureLog(t)
+	setLogging(t, LevelInfo, FormatJSON, false)
+
+	log := newLogger("10.8.9")
+	log.SetStage("analyze")
+	log.Warnf("first\nsecond")
+	log.Println()
+	log.Output("stderr", "plain output")
+	log.Output("stdout", `{"time":"2022-01-01T00:00:00Z","level":"error","msg":"child failed"}`)
+	log.Output("stdout", `{"level":"debug","msg":"hidden"}`)
+
+	var records []logRecord
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record logRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("invalid JSON line %q: %s", line, err)
+		}
+
+		records = append(records, record)
+	}
+
+	if len(records) != 4 {
+		t.Fatalf("incorrect number of records: %+v", records)
+	}
+
+	for _, record := range records {
+		if record.Server != "10.8.9" || record.Stage != "analyze" || record.Time == "" {
+			t.Errorf("record is missing fields: %+v", record)
+		}
+	}
+
+	if records[1].Level != "warn" || records[1].Message != "second" {
+		t.Errorf("incorrect message record: %+v", records[1])
+	}
+
+	if records[2].Level != "info" || records[2].Stream != "stderr" || records[2].Message != "plain output" {
+		t.Errorf("incorrect output record: %+v", records[2])
+	}
+
+	// Records logged by the verifier are passed through with their level
+	if records[3].Level != "error" || records[3].Stream != "stdout" || records[3].Message != "child failed" {
+		t.Errorf("incorrect child record: %+v", records[3])
+	}
+}
+
+func TestLoggingArguments(t *testing.T) {
+	setLogging(t, LevelInfo, FormatText, false)
+	if args := loggingArguments(); len(args) != 0 {
+		t.Errorf("default options were passed on: %v", args)
+	}
+
+	setLogging(t, LevelDebug, FormatJSON, false)
+	if args := strings.Join(loggingArguments(), " "); args != "-log-format json -log-level debug" {
+		t.Errorf("incorrect arguments: %s", args)
+	}
+}
+
+func TestLineWriter(t *testing.T) {
+	buf := captureLog(t)
+	w := newLogger("a").Writer()
+
+	w.Write([]byte("partial "))
+	if buf.Len() != 0 {
+		t.Errorf("partial line was logged early: %q", buf.String())
+	}
+
+	w.Write([]byte("line\nsecond"))
+	w.Flush()
+
+	if expected := "[a] partial line\n[a] second\n"; buf.String() != expected {
+		t.Errorf("lines were logged incorrectly: %q", buf.String())
+	}
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"bufio"
 	"bytes"
 	"crypto/rand"
 	"encoding/hex"
@@ -9,10 +8,12 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"os"
-	"path"
+	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -25,11 +26,48 @@ var pluginPath string
 // Randomly generated password used to setup container with.
 var containerPassword string
 
+// File version of the plugin DLL installed in local containers.
+var installedVersion string
+
+// Path to save the JUnit report of all servers and stages to.
+var junitPath string
+
+// Path to the configuration file.
+var configPath string
+
+// Path to the plugin manifest used to build the local plugin repository.
+var manifestPath string
+
+// Configuration fields to override, in the form "servers[0].image=value".
+var configOverrides stringList
+
+// Comments of the servers to test. If empty, all servers which are not skipped are tested.
+var onlyServers stringList
+
+// Print the plan of what would be tested and all commands that would be run without running anything.
+var dryRun bool
+
+// Remove everything left behind by earlier runs instead of testing.
+var cleanup bool
+
 func flags() {
 	flag.StringVar(&pluginPath, "dll", "", "Path to plugin DLL to install in container images.")
 	flag.StringVar(&containerAddress, "caddr", "", "IP address to use when connecting to local containers.")
+	flag.StringVar(&configPath, "config", "config.json", "Path to the configuration file. Comments and trailing commas are allowed.")
+	flag.Var(&configOverrides, "set", "Override a configuration field, i.e. servers[0].image=jellyfin/jellyfin:10.8.4. Can be repeated.")
+	flag.Var(&onlyServers, "only", "Only test the server with this comment. Can be repeated.")
+	flag.BoolVar(&dryRun, "dry-run", false, "Print the servers and stages that would be tested and every command that would be run, without running anything.")
+	flag.StringVar(&manifestPath, "manifest", "../../manifest.json", "Path to the plugin manifest used to build the local plugin repository.")
+	flag.StringVar(&junitPath, "junit", "", "Path to save the JUnit report to. Defaults to reports/junit-TIMESTAMP.xml.")
+	flag.StringVar(&artifactsRoot, "artifacts", "reports/artifacts", "Directory to save container logs and plugin files of local servers to. Set to an empty string to disable.")
+	flag.BoolVar(&cleanup, "cleanup", false, "Remove containers, configuration directories and Selenium services left behind by earlier runs which crashed or were killed, then exit.")
+	flag.Var(&logLevel, "log-level", "Minimum level of messages to log. One of debug, info, warn or error.")
+	flag.Var(&logFormat, "log-format", "Log format. Either text or json (one JSON object per line).")
+	flag.Var(&logColor, "color", "Colorize output. One of auto, always or never. auto only colorizes output to a terminal.")
 	flag.Parse()
 
+	configureLogging()
+
 	// Randomize the container's password
 	rawPassword := make([]byte, 32)
 	if _, err := rand.Read(rawPassword); err != nil {
@@ -37,203 +75,229 @@ func flags() {
 	}
 
 	containerPassword = hex.EncodeToString(rawPassword)
+	registerSecret(containerPassword)
 }
 
 func main() {
+	os.Exit(run())
+}
+
+// Run all tests and return the process exit code. Returns 1 if testing any server failed.
+func run() int {
 	flags()
 
+	if cleanup {
+		return cleanupLeftovers()
+	}
+
+	// Anything that was setup is cleaned up before exiting, even if testing is interrupted or the wrapper panics
+	defer runAllTeardowns(rootLog)
+	defer handleInterrupts()()
+
 	start := time.Now()
 
-	fmt.Printf("[+] Start time: %s\n", start)
+	rootLog.Infof("Start time: %s", start)
 
 	// Load list of servers
-	fmt.Println("[+] Loading configuration")
-	config := loadConfiguration()
-	fmt.Println()
-
-	// Start Selenium by bringing up the compose file in detatched mode
-	fmt.Println("[+] Starting Selenium")
-	RunProgram("docker-compose", []string{"up", "-d"}, 10*time.Second)
+	rootLog.Infof("Loading configuration")
+	config, err := loadConfiguration(configPath)
+	if err != nil {
+		rootLog.Errorf("Invalid configuration in %s:", configPath)
+		for _, line := range strings.Split(err.Error(), "\n") {
+			rootLog.Errorf("%s", line)
+		}
 
-	// If any error occurs, bring Selenium down before exiting
-	defer func() {
-		fmt.Println("[+] Stopping Selenium")
-		RunProgram("docker-compose", []string{"down"}, 15*time.Second)
-	}()
+		return 1
+	}
+	rootLog.Println()
 
-	// Test all provided Jellyfin servers
-	for _, server := range config.Servers {
-		if server.Skip {
-			continue
+	// Read the version of the plugin that will be installed so the support bundle can be checked against it
+	if pluginPath != "" {
+		version, err := pluginVersion(pluginPath)
+		if err != nil {
+			rootLog.Warnf("Unable to read plugin version from %s: %s", pluginPath, err)
+		} else {
+			rootLog.Infof("Plugin version: %s", version)
+			installedVersion = version
 		}
+	}
 
-		var configurationDirectory string
-		var apiKey string
-		var seleniumArgs []string
+	plan := buildPlan(config)
+	if dryRun {
+		out := rootLog.Writer()
+		defer out.Flush()
 
-		// LSIO containers use some slighly different paths & permissions
-		lsioImage := strings.Contains(server.Image, "linuxserver")
+		if err := printPlan(out, plan, start); err != nil {
+			rootLog.Errorf("%s", err)
+			return 1
+		}
 
-		fmt.Println()
-		fmt.Printf("[+] Testing %s\n", server.Comment)
+		return 0
+	}
 
-		if server.Docker {
-			var err error
+	// Serve the plugin from a local repository for servers which install it through the Packages API
+	if plan.Repository {
+		if installedVersion == "" {
+			rootLog.Errorf("The plugin version is required to install the plugin from a repository")
+			return 1
+		}
 
-			// Setup a temporary folder for the container's configuration
-			configurationDirectory, err = os.MkdirTemp("/dev/shm", "jf-e2e-*")
-			if err != nil {
-				panic(err)
-			}
+		repo, err := startRepository(manifestPath, pluginPath, installedVersion)
+		if err != nil {
+			rootLog.Errorf("Unable to start plugin repository: %s", err)
+			return 1
+		}
 
-			// Create a folder to install the plugin into
-			pluginDirectory := path.Join(configurationDirectory, "plugins", "intro-skipper")
-			if lsioImage {
-				pluginDirectory = path.Join(configurationDirectory, "data", "plugins", "intro-skipper")
-			}
+		rootLog.Infof("Serving plugin repository at %s", repo.URL)
+		repository = repo
+	}
 
-			fmt.Println("  [+] Creating plugin directory")
-			if err := os.MkdirAll(pluginDirectory, 0700); err != nil {
-				fmt.Printf("  [!] Failed to create plugin directory: %s\n", err)
-				goto cleanup
-			}
+	// Select the container runtime used to manage Selenium
+	runtime, err := newContainerRuntime(plan.Common.Runtime, rootLog)
+	if err != nil {
+		panic(err)
+	}
 
-			// If this is an LSIO container, adjust the permissions on the plugin directory
-			if lsioImage {
-				RunProgram(
-					"chown",
-					[]string{
-						"911:911",
-						"-R",
-						path.Join(configurationDirectory, "data", "plugins")},
-					2*time.Second)
-			}
+	// If any error occurs, bring Selenium down before exiting. Registered before Selenium is started so that any
+	// containers which were started are brought down even if starting the rest fails or is interrupted.
+	selenium := registerTeardown("Selenium", func() error {
+		rootLog.Infof("Stopping Selenium")
+		return runtime.Detached().ComposeDown()
+	})
 
-			// Install the plugin
-			fmt.Printf("  [+] Copying plugin %s to %s\n", pluginPath, pluginDirectory)
-			RunProgram("cp", []string{pluginPath, pluginDirectory}, 2*time.Second)
-			fmt.Println()
-
-			/* Start the container with the following settings:
-			 *    Name:  jf-e2e
-			 *    Port:  8097
-			 *    Media: Mounted to /media, read only
-			 */
-			containerArgs := []string{"run", "--name", "jf-e2e", "--rm", "-p", "8097:8096",
-				"-v", fmt.Sprintf("%s:%s:rw", configurationDirectory, "/config"),
-				"-v", fmt.Sprintf("%s:%s:ro", config.Common.Library, "/media"),
-				server.Image}
-
-			fmt.Printf("  [+] Starting container %s\n", server.Image)
-			go RunProgram("docker", containerArgs, 60*time.Second)
-
-			// Wait for the container to fully start
-			waitForServerStartup(server.Address)
-			fmt.Println()
-
-			fmt.Println("  [+] Setting up container")
-
-			// Set up the container
-			SetupServer(server.Address, containerPassword)
-
-			// Restart the container and wait for it to come back up
-			RunProgram("docker", []string{"restart", "jf-e2e"}, 10*time.Second)
-			time.Sleep(time.Second)
-			waitForServerStartup(server.Address)
-			fmt.Println()
-		} else {
-			fmt.Println("[+] Remote instance, assuming plugin is already installed")
+	defer func() {
+		if err := runTeardown(selenium); err != nil {
+			rootLog.Errorf("Failed to stop Selenium: %s", err)
 		}
+	}()
 
-		// Get an API key
-		apiKey = login(server)
-
-		// Rescan the library if this is a server that we just setup
-		if server.Docker {
-			fmt.Println("  [+] Rescanning library")
+	// Start Selenium by bringing up the compose file in detatched mode
+	rootLog.Infof("Starting Selenium")
+	if err := runtime.ComposeUp(); err != nil {
+		panic(err)
+	}
 
-			sendRequest(
-				server.Address+"/ScheduledTasks/Running/7738148ffcd07979c7ceb148e06b3aed?api_key="+apiKey,
-				"POST",
-				"")
+	// Test all planned Jellyfin servers, running up to MaxParallelism tests at once
+	var wg sync.WaitGroup
+	results := make([]*ServerResult, len(plan.Servers))
+	slots := make(chan struct{}, plan.Common.MaxParallelism)
 
-			// TODO: poll for task completion
-			time.Sleep(10 * time.Second)
+	for i, planned := range plan.Servers {
+		slots <- struct{}{}
 
-			fmt.Println()
+		// Servers which have not started yet are not tested once interrupted
+		if interrupted() {
+			break
 		}
 
-		// Analyze episodes and save report
-		fmt.Println("  [+] Analyzing episodes")
-		fmt.Print("\033[37;1m") // change the color of the verifier's text
-		RunProgram(
-			"./verifier/verifier",
-			[]string{
-				"-address", server.Address,
-				"-key", apiKey, "-o",
-				fmt.Sprintf("reports/%s-%d.json", server.Comment, start.Unix())},
-			5*time.Minute)
-		fmt.Print("\033[39;0m") // reset terminal text color
-
-		// Pause for any manual tests
-		if server.ManualTests {
-			fmt.Println("  [!] Pausing for manual tests")
-			reader := bufio.NewReader(os.Stdin)
-			reader.ReadString('\n')
+		wg.Add(1)
+		go func(i int, planned ServerPlan) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			// A panic outside of a stage would otherwise exit without cleaning up any server
+			defer func() {
+				if r := recover(); r != nil {
+					results[i] = &ServerResult{
+						Name:   serverName(planned.Index, planned.Server),
+						Server: planned.Server,
+						Stages: []StageResult{{Name: "panic", Status: StageFailed, Err: fmt.Errorf("panic: %v", r)}},
+					}
+				}
+			}()
+
+			result := testServer(planned, plan.Common, start)
+			results[i] = &result
+		}(i, planned)
+	}
+
+	wg.Wait()
+	rootLog.Println()
+
+	// Collect the results of all tested servers in configuration order
+	var summary []ServerResult
+	passed := true
+	for _, result := range results {
+		if result == nil {
+			continue
 		}
 
-		// Setup base Selenium arguments
-		seleniumArgs = []string{
-			"-u", // force stdout to be unbuffered
-			"main.py",
-			"-host", server.Address,
-			"-user", server.Username,
-			"-pass", server.Password,
-			"-name", config.Common.Episode}
-
-		// Append all requested Selenium tests
-		seleniumArgs = append(seleniumArgs, "--tests")
-		seleniumArgs = append(seleniumArgs, server.Tests...)
-
-		// Append all requested browsers
-		seleniumArgs = append(seleniumArgs, "--browsers")
-		seleniumArgs = append(seleniumArgs, server.Browsers...)
-
-		// Run Selenium
-		os.Chdir("selenium")
-		RunProgram("python3", seleniumArgs, time.Minute)
-		os.Chdir("..")
-
-	cleanup:
-		if server.Docker {
-			// Stop the container
-			fmt.Println("  [+] Stopping and removing container")
-			RunProgram("docker", []string{"stop", "jf-e2e"}, 10*time.Second)
+		summary = append(summary, *result)
+		passed = passed && result.Passed()
+	}
 
-			// Cleanup the container's configuration
-			fmt.Printf("  [+] Deleting %s\n", configurationDirectory)
+	rootLog.Infof("Results")
+	out := rootLog.Writer()
+	printSummary(out, summary)
+	fmt.Fprintln(out)
+	printMatrixSummary(out, summary)
+	out.Flush()
 
-			if err := os.RemoveAll(configurationDirectory); err != nil {
-				panic(err)
-			}
-		}
+	if junitPath == "" {
+		junitPath = fmt.Sprintf("reports/junit-%d.xml", start.Unix())
+	}
+
+	// Artifacts are only saved for local servers
+	artifacts := filepath.Join(artifactsRoot, fmt.Sprint(start.Unix()))
+	if _, err := os.Stat(artifacts); artifactsRoot != "" && err == nil {
+		rootLog.Infof("Saved artifacts to %s", artifacts)
+	}
+
+	if err := writeJUnit(junitPath, summary); err != nil {
+		rootLog.Errorf("Failed to write JUnit report: %s", err)
+	} else {
+		rootLog.Infof("Saved JUnit report to %s", junitPath)
+	}
+
+	if interrupted() {
+		rootLog.Errorf("Testing was interrupted")
+		return 1
 	}
+
+	if !passed {
+		rootLog.Errorf("Testing failed")
+		return 1
+	}
+
+	rootLog.Infof("All servers passed")
+	return 0
+}
+
+// Returns the name used to prefix all output related to a server.
+func serverName(index int, server Server) string {
+	if server.Comment != "" {
+		return server.Comment
+	}
+
+	return fmt.Sprintf("server %d", index)
+}
+
+// Returns a currently unused TCP port on the host.
+func freePort() (int, error) {
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+
+	return listener.Addr().(*net.TCPAddr).Port, nil
 }
 
 // Login to the specified Jellyfin server and return an API key
-func login(server Server) string {
+func login(log *Logger, server Server) string {
 	type AuthenticateUserByName struct {
 		AccessToken string
 	}
 
-	fmt.Println("  [+] Sending authentication request")
+	log.Infof("Sending authentication request")
 
 	// Create request body
 	rawBody := fmt.Sprintf(`{"Username":"%s","Pw":"%s"}`, server.Username, server.Password)
 	body := bytes.NewBufferString(rawBody)
 
 	// Create the request
-	req, err := http.NewRequest(
+	req, err := http.NewRequestWithContext(
+		runContext,
 		"POST",
 		fmt.Sprintf("%s/Users/AuthenticateByName", server.Address),
 		body)
@@ -273,52 +337,51 @@ func login(server Server) string {
 	return token.AccessToken
 }
 
-// Wait up to ten seconds for the provided Jellyfin server to fully startup
-func waitForServerStartup(address string) {
-	attempts := 10
-	fmt.Println("  [+] Waiting for server to finish starting")
-
-	for {
-		// Sleep in between requests
-		time.Sleep(time.Second)
-
-		// Ping the /System/Info/Public endpoint
-		res, err := http.Get(fmt.Sprintf("%s/System/Info/Public", address))
+// Read and validate the configuration file
+func loadConfiguration(path string) (Configuration, error) {
+	// Load the contents of the configuration file
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return Configuration{}, err
+	}
 
-		// If the server didn't return 200 OK, loop
-		if err != nil || res.StatusCode != http.StatusOK {
-			if attempts--; attempts <= 0 {
-				panic("server is taking too long to startup")
-			}
+	// Collect overrides from the environment first so that command line arguments take precedence
+	overrides, err := environmentOverrides(rootLog, os.Environ())
+	if err != nil {
+		return Configuration{}, err
+	}
 
-			continue
+	for _, raw := range configOverrides {
+		o, err := parseOverride(raw)
+		if err != nil {
+			return Configuration{}, err
 		}
 
-		// Assume startup has finished, break
-		break
+		overrides = append(overrides, o)
 	}
-}
-
-// Read configuration from config.json
-func loadConfiguration() Configuration {
-	var config Configuration
 
-	// Load the contents of the configuration file
-	raw, err := os.ReadFile("config.json")
+	// Unmarshal, override and validate
+	config, err := parseConfiguration(raw, overrides)
 	if err != nil {
-		panic(err)
+		return config, err
 	}
 
-	// Unmarshal
-	if err := json.Unmarshal(raw, &config); err != nil {
-		panic(err)
+	if err := selectServers(&config, onlyServers); err != nil {
+		return config, err
+	}
+
+	// Default to testing one server at a time
+	if config.Common.MaxParallelism <= 0 {
+		config.Common.MaxParallelism = 1
 	}
 
 	// Print debugging info
-	fmt.Printf("Library:  %s\n", config.Common.Library)
-	fmt.Printf("Episode:  \"%s\"\n", config.Common.Episode)
-	fmt.Printf("Password: %s\n", containerPassword)
-	fmt.Println()
+	for _, library := range resolveLibraries(config.Common) {
+		rootLog.Printf("Library:  %s (%s) %v\n", library.Name, library.CollectionType, library.Paths)
+	}
+	rootLog.Printf("Parallel: %d\n", config.Common.MaxParallelism)
+	rootLog.Printf("Episode:  \"%s\"\n", config.Common.Episode)
+	rootLog.Println()
 
 	// Check the validity of all entries
 	for i, server := range config.Servers {
@@ -327,19 +390,20 @@ func loadConfiguration() Configuration {
 			// Ensure that values were provided for the host's IP address, base configuration directory,
 			// and a path to the compiled plugin DLL to install.
 			if containerAddress == "" {
-				panic("The -caddr argument is required.")
+				return config, fmt.Errorf("servers[%d].image: the -caddr argument is required", i)
 			}
 
 			if pluginPath == "" {
-				panic("The -dll argument is required.")
+				return config, fmt.Errorf("servers[%d].image: the -dll argument is required", i)
 			}
 
 			server.Username = "admin"
 			server.Password = containerPassword
-			server.Address = fmt.Sprintf("http://%s:8097", containerAddress)
 			server.Docker = true
 		}
 
+		registerSecret(server.Password)
+
 		// If no browsers were specified, default to Chrome (for speed)
 		if len(server.Browsers) == 0 {
 			server.Browsers = []string{"chrome"}
@@ -350,31 +414,30 @@ func loadConfiguration() Configuration {
 			server.Tests = []string{"settings"}
 		}
 
-		// Verify that an address was provided
-		if len(server.Address) == 0 {
-			panic("Server address is required")
-		}
-
-		fmt.Printf("===== Server: %s =====\n", server.Comment)
+		rootLog.Printf("===== Server: %s =====\n", server.Comment)
 
 		if server.Skip {
-			fmt.Println("Skip:     true")
+			rootLog.Println("Skip:     true")
 		}
 
-		fmt.Printf("Docker:   %t\n", server.Docker)
+		rootLog.Printf("Docker:   %t\n", server.Docker)
 		if server.Docker {
-			fmt.Printf("Image:    %s\n", server.Image)
+			rootLog.Printf("Image:    %s\n", server.Image)
 		}
 
-		fmt.Printf("Address:  %s\n", server.Address)
-		fmt.Printf("Browsers: %v\n", server.Browsers)
-		fmt.Printf("Tests:    %v\n", server.Tests)
-		fmt.Println()
+		if server.Docker {
+			rootLog.Println("Address:  allocated when the container is started")
+		} else {
+			rootLog.Printf("Address:  %s\n", server.Address)
+		}
+		rootLog.Printf("Browsers: %v\n", server.Browsers)
+		rootLog.Printf("Tests:    %v\n", server.Tests)
+		rootLog.Println()
 
 		config.Servers[i] = server
 	}
 
-	fmt.Println("=================")
+	rootLog.Println("=================")
 
-	return config
+	return config, nil
 }
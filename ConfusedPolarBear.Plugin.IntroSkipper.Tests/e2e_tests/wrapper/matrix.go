@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+)
+
+// Characters which are not allowed in the comment of an expanded server, as comments are used in report filenames.
+var unsafeCommentRegex = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Expands every matrix into concrete servers and appends them to the list of servers to test.
+func expandMatrices(config *Configuration) {
+	for i, matrix := range config.Matrix {
+		config.Servers = append(config.Servers, expandMatrix(i, matrix)...)
+	}
+}
+
+// Expands a single matrix into one server for every combination of image, tag and browser.
+func expandMatrix(index int, matrix Matrix) []Server {
+	name := matrix.Template.Comment
+	if name == "" {
+		name = fmt.Sprintf("matrix %d", index)
+	}
+
+	// Without tags, images are used exactly as provided
+	var images []string
+	for _, image := range matrix.Images {
+		if len(matrix.Tags) == 0 {
+			images = append(images, image)
+			continue
+		}
+
+		for _, tag := range matrix.Tags {
+			images = append(images, image+":"+tag)
+		}
+	}
+
+	// Without browsers, every run tests all of the template's browsers
+	browsers := [][]string{matrix.Template.Browsers}
+	if len(matrix.Browsers) > 0 {
+		browsers = nil
+		for _, browser := range matrix.Browsers {
+			browsers = append(browsers, []string{browser})
+		}
+	}
+
+	var servers []Server
+	for _, image := range images {
+		for _, browser := range browsers {
+			server := matrix.Template
+			server.Image = image
+			server.Browsers = browser
+			server.Tests = append([]string{}, matrix.Template.Tests...)
+
+			parts := []string{name, image}
+			if len(matrix.Browsers) > 0 {
+				parts = append(parts, browser[0])
+			}
+
+			server.Comment = unsafeCommentRegex.ReplaceAllString(strings.Join(parts, "-"), "_")
+			server.Matrix = name
+			server.MatrixImage = image
+
+			servers = append(servers, server)
+		}
+	}
+
+	return servers
+}
+
+// Status of every test stage of every image in a matrix, combined across all browsers.
+type matrixTable struct {
+	Name   string
+	Images []string
+	Tests  []string
+
+	// Status of each test, indexed by image and then test name.
+	Status map[string]map[string]StageStatus
+}
+
+// Returns the names of all stages which test the plugin, in the order they are run.
+func testStageNames() []string {
+	var names []string
+	for _, stages := range [][]Stage{serverStages, upgradeStages} {
+		for _, stage := range stages {
+			if stage.Test && !contains(names, stage.Name) {
+				names = append(names, stage.Name)
+			}
+		}
+	}
+
+	return names
+}
+
+// Groups the results of all servers expanded from a matrix into one table per matrix. UI tests are reported
+// separately for each browser, all other tests are combined: a test fails if it failed with any browser.
+func matrixTables(results []ServerResult) []matrixTable {
+	var tables []*matrixTable
+	byName := make(map[string]*matrixTable)
+
+	tests := make(map[string]bool)
+	for _, name := range testStageNames() {
+		tests[name] = true
+	}
+
+	for _, result := range results {
+		server := result.Server
+		if server.Matrix == "" {
+			continue
+		}
+
+		table, ok := byName[server.Matrix]
+		if !ok {
+			table = &matrixTable{Name: server.Matrix, Status: make(map[string]map[string]StageStatus)}
+			byName[server.Matrix] = table
+			tables = append(tables, table)
+		}
+
+		statuses, ok := table.Status[server.MatrixImage]
+		if !ok {
+			statuses = make(map[string]StageStatus)
+			table.Status[server.MatrixImage] = statuses
+			table.Images = append(table.Images, server.MatrixImage)
+		}
+
+		for _, stage := range result.Stages {
+			if !tests[stage.Name] {
+				continue
+			}
+
+			name := stage.Name
+			if name == "ui tests" {
+				name = fmt.Sprintf("%s (%s)", name, strings.Join(server.Browsers, ", "))
+			}
+
+			previous, seen := statuses[name]
+			if !seen {
+				if !contains(table.Tests, name) {
+					table.Tests = append(table.Tests, name)
+				}
+
+				statuses[name] = stage.Status
+				continue
+			}
+
+			statuses[name] = combineStatus(previous, stage.Status)
+		}
+	}
+
+	var combined []matrixTable
+	for _, table := range tables {
+		combined = append(combined, *table)
+	}
+
+	return combined
+}
+
+// Combines the status of the same test from two runs. Failures take precedence over passes, which take precedence
+// over skips.
+func combineStatus(a, b StageStatus) StageStatus {
+	switch {
+	case a == StageFailed || b == StageFailed:
+		return StageFailed
+
+	case a == StagePassed || b == StagePassed:
+		return StagePassed
+
+	default:
+		return StageSkipped
+	}
+}
+
+// Prints a table of images and tests for every matrix.
+func printMatrixSummary(w io.Writer, results []ServerResult) {
+	for _, matrix := range matrixTables(results) {
+		fmt.Fprintf(w, "[+] Matrix: %s\n", matrix.Name)
+
+		table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(table, "IMAGE\t%s\n", strings.ToUpper(strings.Join(matrix.Tests, "\t")))
+
+		for _, image := range matrix.Images {
+			row := []string{image}
+			for _, test := range matrix.Tests {
+				status, ok := matrix.Status[image][test]
+				if !ok {
+					status = "-"
+				}
+
+				row = append(row, string(status))
+			}
+
+			fmt.Fprintln(table, strings.Join(row, "\t"))
+		}
+
+		table.Flush()
+		fmt.Fprintln(w)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestExpandMatrix(t *testing.T) {
+	raw := `{
+    "common": {"library": "/tv"},
+    "matrix": [{
+        "template": {"comment": "release", "tests": ["skip_button"]},
+        "images": ["jellyfin/jellyfin", "lscr.io/linuxserver/jellyfin"],
+        "tags": ["10.8.4", "10.8.9"],
+        "browsers": ["chrome", "firefox"],
+    }],
+}`
+
+	config, err := parseConfiguration([]byte(raw), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(config.Servers) != 8 {
+		t.Fatalf("expected 8 expanded servers, found %d", len(config.Servers))
+	}
+
+	first, last := config.Servers[0], config.Servers[7]
+	if first.Image != "jellyfin/jellyfin:10.8.4" || first.Browsers[0] != "chrome" || first.Tests[0] != "skip_button" {
+		t.Errorf("first server was expanded incorrectly: %+v", first)
+	}
+
+	if last.Comment != "release-lscr.io_linuxserver_jellyfin_10.8.9-firefox" {
+		t.Errorf("comment is not safe to use in a filename: %s", last.Comment)
+	}
+
+	if last.Matrix != "release" || last.MatrixImage != "lscr.io/linuxserver/jellyfin:10.8.9" {
+		t.Errorf("matrix was not recorded: %+v", last)
+	}
+}
+
+func TestInvalidMatrix(t *testing.T) {
+	raw := `{"matrix": [{"template": {"image": "jellyfin/jellyfin", "tests": ["setings"]}, "browsers": ["edge"]}]}`
+
+	_, err := parseConfiguration([]byte(raw), nil)
+	if err == nil {
+		t.Fatal("invalid matrix was accepted")
+	}
+
+	for _, expected := range []string{
+		"matrix[0].images: at least one image is required",
+		"matrix[0].template: images are set by the matrix",
+		`matrix[0].browsers[0]: unknown browser "edge"`,
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("error does not contain %q:\n%s", expected, err)
+		}
+	}
+}
+
+func TestMatrixSummary(t *testing.T) {
+	result := func(image, browser string, analyze, ui StageStatus) ServerResult {
+		return ServerResult{
+			Server: Server{Matrix: "release", MatrixImage: image, Browsers: []string{browser}},
+			Stages: []StageResult{
+				{Name: "login", Status: StagePassed},
+				{Name: "analyze", Status: analyze},
+				{Name: "ui tests", Status: ui},
+			},
+		}
+	}
+
+	results := []ServerResult{
+		result("jellyfin:10.8.4", "chrome", StagePassed, StagePassed),
+		result("jellyfin:10.8.4", "firefox", StageFailed, StageSkipped),
+		result("jellyfin:10.8.9", "chrome", StagePassed, StageFailed),
+		{Name: "standalone", Stages: []StageResult{{Name: "analyze", Status: StagePassed}}},
+	}
+
+	tables := matrixTables(results)
+	if len(tables) != 1 {
+		t.Fatalf("expected one matrix, found %d", len(tables))
+	}
+
+	status := tables[0].Status
+	if status["jellyfin:10.8.4"]["analyze"] != StageFailed {
+		t.Error("a test which failed with any browser should be reported as failed")
+	}
+
+	if status["jellyfin:10.8.4"]["ui tests (chrome)"] != StagePassed ||
+		status["jellyfin:10.8.4"]["ui tests (firefox)"] != StageSkipped {
+		t.Errorf("UI tests should be reported separately for each browser: %v", status["jellyfin:10.8.4"])
+	}
+
+	if _, ok := status["jellyfin:10.8.4"]["login"]; ok {
+		t.Error("stages which do not test the plugin should not be included")
+	}
+
+	var table bytes.Buffer
+	printMatrixSummary(&table, results)
+
+	lines := strings.Split(strings.TrimSpace(table.String()), "\n")
+	if len(lines) != 4 || !strings.Contains(lines[1], "UI TESTS (FIREFOX)") {
+		t.Errorf("matrix table was printed incorrectly:\n%s", table.String())
+	}
+
+	// Tests which were not run for an image are shown as a dash
+	if !strings.HasSuffix(strings.TrimSpace(lines[3]), "-") {
+		t.Errorf("missing test was printed incorrectly: %s", lines[3])
+	}
+}
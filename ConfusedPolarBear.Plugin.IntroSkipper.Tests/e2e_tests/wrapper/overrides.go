@@ -0,0 +1,325 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Prefix of environment variables which override configuration fields.
+const environmentPrefix = "E2E_"
+
+// Replaces a single configuration field, i.e. "servers[0].image" with "jellyfin/jellyfin:10.8.4".
+type override struct {
+	// Where the override came from, used in error messages.
+	Source string
+
+	// Path to the field. Array indices are either a number or "*" to select every element.
+	Path []string
+
+	Value string
+}
+
+// Flag which can be provided multiple times.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// Parses an override in the form "servers[0].image=value".
+func parseOverride(raw string) (override, error) {
+	parts := strings.SplitN(raw, "=", 2)
+	if len(parts) != 2 {
+		return override{}, fmt.Errorf("-set %s: expected path=value", raw)
+	}
+
+	path, err := splitPath(parts[0])
+	if err != nil {
+		return override{}, fmt.Errorf("-set %s: %w", parts[0], err)
+	}
+
+	return override{Source: "-set " + parts[0], Path: path, Value: parts[1]}, nil
+}
+
+// Splits "servers[0].thresholds.max_lost" into ["servers", "0", "thresholds", "max_lost"].
+func splitPath(raw string) ([]string, error) {
+	var path []string
+
+	for _, part := range strings.Split(raw, ".") {
+		name := part
+		index := ""
+
+		if open := strings.IndexByte(part, '['); open != -1 {
+			if !strings.HasSuffix(part, "]") {
+				return nil, fmt.Errorf("unterminated index in %q", part)
+			}
+
+			name, index = part[:open], part[open+1:len(part)-1]
+		}
+
+		if name == "" {
+			return nil, fmt.Errorf("empty field name in %q", raw)
+		}
+
+		path = append(path, name)
+		if index != "" {
+			path = append(path, index)
+		}
+	}
+
+	return path, nil
+}
+
+// Returns an override for every environment variable starting with E2E_. Variables are named after the path of the
+// field they override in upper case, i.e. E2E_COMMON_LIBRARY or E2E_SERVERS_0_PASSWORD. Use ALL in place of a server
+// index to override the field for every server.
+//
+// Other tools also use the E2E_ prefix, so variables which do not start with the name of a top level field are
+// skipped with a warning. Variables which do are rejected if the rest of their name is invalid.
+func environmentOverrides(log *Logger, environ []string) ([]override, error) {
+	var overrides []override
+	fields := jsonFields(reflect.TypeOf(Configuration{}))
+
+	for _, variable := range environ {
+		parts := strings.SplitN(variable, "=", 2)
+		if len(parts) != 2 || !strings.HasPrefix(parts[0], environmentPrefix) {
+			continue
+		}
+
+		name := strings.ToLower(strings.TrimPrefix(parts[0], environmentPrefix))
+
+		known := false
+		for field := range fields {
+			if name == field || strings.HasPrefix(name, field+"_") {
+				known = true
+				break
+			}
+		}
+
+		if !known {
+			log.Warnf("Ignoring environment variable %s, which does not override a configuration field", parts[0])
+			continue
+		}
+
+		path, err := environmentPath(name, reflect.TypeOf(Configuration{}))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", parts[0], err)
+		}
+
+		overrides = append(overrides, override{Source: parts[0], Path: path, Value: parts[1]})
+	}
+
+	return overrides, nil
+}
+
+// Converts an environment variable name (without the prefix, in lower case) into a path. Since field names can
+// contain underscores, each segment is matched against the fields of the type it belongs to.
+func environmentPath(name string, typ reflect.Type) ([]string, error) {
+	switch typ.Kind() {
+	case reflect.Ptr:
+		return environmentPath(name, typ.Elem())
+
+	case reflect.Slice:
+		parts := strings.SplitN(name, "_", 2)
+		index := parts[0]
+		if index == "all" {
+			index = "*"
+		} else if _, err := strconv.Atoi(index); err != nil {
+			return nil, fmt.Errorf("expected an index or ALL, found %q", parts[0])
+		}
+
+		if len(parts) == 1 {
+			return nil, fmt.Errorf("missing field name after index %s", parts[0])
+		}
+
+		rest, err := environmentPath(parts[1], typ.Elem())
+		if err != nil {
+			return nil, err
+		}
+
+		return append([]string{index}, rest...), nil
+
+	case reflect.Struct:
+		fields := jsonFields(typ)
+		if _, ok := fields[name]; ok {
+			return []string{name}, nil
+		}
+
+		for field, fieldType := range fields {
+			if !strings.HasPrefix(name, field+"_") {
+				continue
+			}
+
+			// Only descend into fields which contain other fields
+			kind := fieldType.Kind()
+			if kind == reflect.Ptr {
+				kind = fieldType.Elem().Kind()
+			}
+
+			if kind != reflect.Struct && !(kind == reflect.Slice && fieldType.Elem().Kind() == reflect.Struct) {
+				continue
+			}
+
+			if rest, err := environmentPath(name[len(field)+1:], fieldType); err == nil {
+				return append([]string{field}, rest...), nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("unknown field %q", name)
+}
+
+// Applies all overrides to a generic JSON value.
+func applyOverrides(tree interface{}, overrides []override) error {
+	for _, o := range overrides {
+		if err := applyOverride(tree, reflect.TypeOf(Configuration{}), o.Path, o.Value); err != nil {
+			return fmt.Errorf("%s: %w", o.Source, err)
+		}
+	}
+
+	return nil
+}
+
+// Sets the field at path in tree, which has the type typ, to value.
+func applyOverride(tree interface{}, typ reflect.Type, path []string, value string) error {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	switch node := tree.(type) {
+	case map[string]interface{}:
+		// Free-form objects (such as plugin settings) accept any key and JSON value
+		if typ.Kind() == reflect.Map {
+			if len(path) != 1 {
+				return fmt.Errorf("%s is not an object", path[1])
+			}
+
+			var parsed interface{} = value
+			json.Unmarshal([]byte(value), &parsed)
+			node[path[0]] = parsed
+			return nil
+		}
+
+		if typ.Kind() != reflect.Struct {
+			return fmt.Errorf("%s is not an object", path[0])
+		}
+
+		fieldType, ok := jsonFields(typ)[path[0]]
+		if !ok {
+			return fmt.Errorf("unknown field %q", path[0])
+		}
+
+		if len(path) == 1 {
+			parsed, err := parseOverrideValue(value, fieldType)
+			if err != nil {
+				return fmt.Errorf("invalid value for %s: %w", path[0], err)
+			}
+
+			node[path[0]] = parsed
+			return nil
+		}
+
+		// Create any missing objects along the path
+		child, ok := node[path[0]]
+		if !ok || child == nil {
+			if fieldType.Kind() == reflect.Slice {
+				return fmt.Errorf("%s has no elements", path[0])
+			}
+
+			child = make(map[string]interface{})
+			node[path[0]] = child
+		}
+
+		return applyOverride(child, fieldType, path[1:], value)
+
+	case []interface{}:
+		if path[0] == "*" {
+			for _, element := range node {
+				if err := applyOverride(element, typ.Elem(), path[1:], value); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}
+
+		index, err := strconv.Atoi(path[0])
+		if err != nil {
+			return fmt.Errorf("invalid index %q", path[0])
+		} else if index < 0 || index >= len(node) {
+			return fmt.Errorf("index %d is out of range (%d elements)", index, len(node))
+		}
+
+		if len(path) == 1 {
+			return fmt.Errorf("expected a field name after index %d", index)
+		}
+
+		return applyOverride(node[index], typ.Elem(), path[1:], value)
+	}
+
+	return fmt.Errorf("%s is not an object", path[0])
+}
+
+// Converts an override's value into a generic JSON value for a field of the provided type. Strings are used as is,
+// lists of strings can also be provided as comma separated values and everything else is parsed as JSON.
+func parseOverrideValue(value string, typ reflect.Type) (interface{}, error) {
+	if typ.Kind() == reflect.String {
+		return value, nil
+	}
+
+	if typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.String && !strings.HasPrefix(value, "[") {
+		var list []interface{}
+		for _, element := range strings.Split(value, ",") {
+			list = append(list, strings.TrimSpace(element))
+		}
+
+		return list, nil
+	}
+
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+		return nil, err
+	}
+
+	return parsed, nil
+}
+
+// Skips all servers whose comment is not in only and tests all servers whose comment is. Returns an error if a
+// comment does not match any server.
+func selectServers(config *Configuration, only []string) error {
+	if len(only) == 0 {
+		return nil
+	}
+
+	selected := make(map[string]bool)
+	for _, comment := range only {
+		selected[comment] = false
+	}
+
+	for i := range config.Servers {
+		server := &config.Servers[i]
+		_, ok := selected[server.Comment]
+		if ok {
+			selected[server.Comment] = true
+		}
+
+		// Explicitly selected servers are tested even if they are skipped in the configuration file
+		server.Skip = !ok
+	}
+
+	for _, comment := range only {
+		if !selected[comment] {
+			return fmt.Errorf("-only: no server has the comment %q", comment)
+		}
+	}
+
+	return nil
+}
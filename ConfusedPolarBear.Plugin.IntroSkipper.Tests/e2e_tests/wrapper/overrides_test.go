@@ -0,0 +1,152 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const overrideTestConfiguration = `{
+    "common": {"library": "/tv"},
+    "servers": [
+        {"comment": "official", "image": "jellyfin/jellyfin:10.8.4"},
+        {"comment": "remote", "address": "https://example.com", "password": "committed"}
+    ]
+}`
+
+func TestOverrides(t *testing.T) {
+	log := captureLog(t)
+
+	environment, err := environmentOverrides(rootLog, []string{
+		"PATH=/usr/bin",
+		"E2E_TOKEN=set by another tool",
+		"E2E_COMMON_MAX_PARALLELISM=2",
+		"E2E_SERVERS_1_PASSWORD=12345",
+		"E2E_SERVERS_ALL_THRESHOLDS_MAX_LOST=0",
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(log.String(), "Ignoring environment variable E2E_TOKEN") {
+		t.Errorf("unrelated environment variable was not reported: %s", log.String())
+	}
+
+	flags := []string{
+		"servers[0].image=lscr.io/linuxserver/jellyfin:latest",
+		"servers[*].browsers=chrome, firefox",
+		"servers[1].skip=true",
+	}
+
+	overrides := environment
+	for _, raw := range flags {
+		o, err := parseOverride(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		overrides = append(overrides, o)
+	}
+
+	config, err := parseConfiguration([]byte(overrideTestConfiguration), overrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Common.MaxParallelism != 2 || config.Common.Library != "/tv" {
+		t.Errorf("common settings were overridden incorrectly: %+v", config.Common)
+	}
+
+	official, remote := config.Servers[0], config.Servers[1]
+
+	if official.Image != "lscr.io/linuxserver/jellyfin:latest" {
+		t.Errorf("image was not overridden: %s", official.Image)
+	}
+
+	// Numeric values must remain strings when the field is a string
+	if remote.Password != "12345" || !remote.Skip {
+		t.Errorf("remote server was overridden incorrectly: %+v", remote)
+	}
+
+	for _, server := range config.Servers {
+		if !reflect.DeepEqual(server.Browsers, []string{"chrome", "firefox"}) {
+			t.Errorf("browsers were not overridden for %s: %v", server.Comment, server.Browsers)
+		}
+
+		if server.Thresholds.MaxLost == nil || *server.Thresholds.MaxLost != 0 {
+			t.Errorf("thresholds were not overridden for %s", server.Comment)
+		}
+	}
+}
+
+func TestInvalidOverrides(t *testing.T) {
+	captureLog(t)
+
+	// Variables which start with a configuration field must be valid
+	for _, variable := range []string{"E2E_SERVERS_0_IMAGES=x", "E2E_SERVERS_FIRST_IMAGE=x", "E2E_COMMON_LIBRARYS=x"} {
+		if _, err := environmentOverrides(rootLog, []string{variable}); err == nil {
+			t.Errorf("invalid environment variable %s was accepted", variable)
+		}
+	}
+
+	for raw, expected := range map[string]string{
+		"servers[2].image=x":   "index 2 is out of range",
+		"servers[0].imag=x":    `unknown field "imag"`,
+		"common.runtime=lxc":   `unsupported container runtime "lxc"`,
+		"servers[0].skip=nope": "invalid value for skip",
+	} {
+		o, err := parseOverride(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = parseConfiguration([]byte(overrideTestConfiguration), []override{o})
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%s: expected error containing %q, found %v", raw, expected, err)
+		}
+	}
+}
+
+func TestSelectServers(t *testing.T) {
+	config := Configuration{Servers: []Server{{Comment: "a"}, {Comment: "b", Skip: true}, {Comment: "c"}}}
+
+	if err := selectServers(&config, []string{"b", "c"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, skip := range []bool{true, false, false} {
+		if config.Servers[i].Skip != skip {
+			t.Errorf("server %s has skip %t, expected %t", config.Servers[i].Comment, config.Servers[i].Skip, skip)
+		}
+	}
+
+	if err := selectServers(&config, []string{"d"}); err == nil {
+		t.Error("selecting an unknown server should fail")
+	}
+}
+
+func TestPluginConfigurationOverride(t *testing.T) {
+	var overrides []override
+	for _, raw := range []string{
+		"servers[0].plugin_configuration.AnalyzeSeasonZero=true",
+		"servers[0].plugin_configuration.EdlAction=Intro",
+	} {
+		o, err := parseOverride(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		overrides = append(overrides, o)
+	}
+
+	config, err := parseConfiguration([]byte(overrideTestConfiguration), overrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	settings := config.Servers[0].PluginConfiguration
+	if settings["AnalyzeSeasonZero"] != true || settings["EdlAction"] != "Intro" {
+		t.Errorf("plugin settings were overridden incorrectly: %v", settings)
+	}
+}
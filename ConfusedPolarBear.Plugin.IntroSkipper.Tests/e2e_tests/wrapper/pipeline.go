@@ -0,0 +1,511 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Outcome of a single stage.
+type StageStatus string
+
+const (
+	StagePassed  StageStatus = "passed"
+	StageFailed  StageStatus = "failed"
+	StageSkipped StageStatus = "skipped"
+)
+
+// Returned by a stage which does not apply to the server being tested.
+var errSkipStage = errors.New("stage skipped")
+
+// A named step in testing a server.
+type Stage struct {
+	Name string
+
+	// Run this stage even if an earlier stage failed. Used for cleanup.
+	Always bool
+
+	// This stage tests the plugin, as opposed to preparing or cleaning up the server. Only tests are included in
+	// matrix summaries.
+	Test bool
+
+	Run func(*serverRun) error
+
+	// Describes the external commands that Run would execute, without running them. Optional.
+	Commands func(*serverRun, *cliRuntime) []Command
+}
+
+// Result of running a single stage.
+type StageResult struct {
+	Name     string
+	Status   StageStatus
+	Duration time.Duration
+
+	// Why the stage failed or was skipped, if applicable.
+	Err error
+
+	// Additional information about the stage's outcome, such as the path to a generated report.
+	Details string
+}
+
+// Results of testing a single server.
+type ServerResult struct {
+	Name   string
+	Server Server
+	Stages []StageResult
+
+	// Outcomes of the server's manual checkpoints, in the order they were performed.
+	Checkpoints []CheckpointResult
+}
+
+// Returns true if no stage failed.
+func (r ServerResult) Passed() bool {
+	for _, stage := range r.Stages {
+		if stage.Status == StageFailed {
+			return false
+		}
+	}
+
+	return true
+}
+
+// State shared between all stages testing a single server.
+type serverRun struct {
+	index   int
+	server  Server
+	common  Common
+	start   time.Time
+	log     *Logger
+	runtime ContainerRuntime
+
+	// Temporary configuration directory of a local server's container.
+	configurationDirectory string
+
+	// Registered action which stops a local server's container and deletes its configuration directory.
+	teardown int
+
+	apiKey     string
+	reportPath string
+
+	// Outcomes of the manual checkpoints which were performed.
+	checkpoints []CheckpointResult
+
+	// State of the plugin's scheduled tasks before the plugin was upgraded.
+	tasksBeforeUpgrade []scheduledTask
+
+	// Additional information about the outcome of the currently running stage.
+	details string
+}
+
+// All stages used to test a server, in the order they are run.
+var serverStages = []Stage{
+	{Name: "container start", Run: stageContainerStart, Commands: describeContainerStart},
+	{Name: "setup", Run: stageSetup, Commands: describeSetup},
+	{Name: "login", Run: stageLogin},
+	{Name: "install", Run: stageInstall, Test: true, Commands: describeInstall},
+	{Name: "configure", Run: stageConfigure},
+	{Name: "scan", Run: stageScan},
+	{Name: "analyze", Run: stageAnalyze, Test: true, Commands: describeAnalyze},
+	{Name: "compare", Run: stageCompare, Test: true, Commands: describeCompare},
+	{Name: "verify", Run: stageVerify, Test: true, Commands: describeVerify},
+	{Name: "checkpoints", Run: stageCheckpoints},
+	{Name: "ui tests", Run: stageUITests, Test: true, Commands: describeUITests},
+	{Name: "artifacts", Run: stageArtifacts, Always: true, Commands: describeArtifacts},
+	{Name: "teardown", Run: stageTeardown, Always: true, Commands: describeTeardown},
+}
+
+// Runs all stages in order. Once a stage fails, all remaining stages are skipped unless they are marked as
+// always running. Panics in a stage are recovered and reported as a failure of that stage.
+func runStages(run *serverRun, stages []Stage) ServerResult {
+	result := ServerResult{
+		Name:   serverName(run.index, run.server),
+		Server: run.server,
+	}
+
+	failed := false
+
+	for _, stage := range stages {
+		if interrupted() && !stage.Always {
+			result.Stages = append(result.Stages, StageResult{
+				Name:   stage.Name,
+				Status: StageSkipped,
+				Err:    errors.New("testing was interrupted"),
+			})
+
+			continue
+		}
+
+		if failed && !stage.Always {
+			result.Stages = append(result.Stages, StageResult{
+				Name:   stage.Name,
+				Status: StageSkipped,
+				Err:    errors.New("an earlier stage failed"),
+			})
+
+			continue
+		}
+
+		stageResult := runStage(run, stage)
+		result.Stages = append(result.Stages, stageResult)
+
+		if stageResult.Status == StageFailed {
+			failed = true
+			run.log.Errorf("Stage %s failed after %s: %s",
+				stage.Name,
+				stageResult.Duration.Round(time.Millisecond),
+				stageResult.Err)
+		}
+	}
+
+	result.Checkpoints = run.checkpoints
+
+	return result
+}
+
+// Runs a single stage, timing it and recovering from any panic.
+func runStage(run *serverRun, stage Stage) (result StageResult) {
+	result.Name = stage.Name
+	start := time.Now()
+	run.details = ""
+
+	defer func() {
+		result.Duration = time.Since(start)
+		result.Details = run.details
+
+		if r := recover(); r != nil {
+			result.Status = StageFailed
+			result.Err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	// Everything logged while the stage runs is tagged with its name
+	run.log.Infof("Stage: %s", stage.Name)
+	run.log.SetStage(stage.Name)
+	defer run.log.SetStage("")
+
+	switch err := stage.Run(run); {
+	case err == nil:
+		result.Status = StagePassed
+
+	case errors.Is(err, errSkipStage):
+		result.Status = StageSkipped
+
+	default:
+		result.Status = StageFailed
+		result.Err = err
+	}
+
+	return result
+}
+
+// Returns the state used to test a server, without a container runtime.
+func newServerRun(planned ServerPlan, common Common, start time.Time) *serverRun {
+	return &serverRun{
+		index:  planned.Index,
+		server: planned.Server,
+		common: common,
+		start:  start,
+		log:    newLogger(serverName(planned.Index, planned.Server)),
+	}
+}
+
+// Run all planned stages against a single server. Local servers are started beforehand and cleaned up afterwards.
+func testServer(planned ServerPlan, common Common, start time.Time) ServerResult {
+	run := newServerRun(planned, common, start)
+
+	// Each server gets its own runtime so that container commands are logged with the server's prefix
+	runtime, err := newContainerRuntime(common.Runtime, run.log)
+	if err != nil {
+		return ServerResult{
+			Name:   serverName(run.index, run.server),
+			Server: run.server,
+			Stages: []StageResult{{Name: "container start", Status: StageFailed, Err: err}},
+		}
+	}
+
+	run.runtime = runtime
+
+	run.log.Infof("Testing %s", run.server.Comment)
+	return runStages(run, planned.Stages)
+}
+
+// Returns the unique name of a local server's container.
+func localContainerName(run *serverRun) string {
+	return fmt.Sprintf("%s-%d-%d", containerName, os.Getpid(), run.index)
+}
+
+// Allocates a unique name and port for a local server's container, starts it and waits for it to finish starting.
+func stageContainerStart(run *serverRun) error {
+	if !run.server.Docker {
+		run.log.Infof("Remote instance, assuming plugin is already installed")
+		return errSkipStage
+	}
+
+	port, err := freePort()
+	if err != nil {
+		return fmt.Errorf("failed to allocate port: %w", err)
+	}
+
+	run.server.ContainerName = localContainerName(run)
+	run.server.Address = fmt.Sprintf("http://%s:%d", containerAddress, port)
+	run.server.Port = port
+
+	// Registered before anything is created so that the container is cleaned up even if the wrapper is interrupted
+	// while it starts. Cleanup commands must still run after an interrupt, so they are not killed by it.
+	runtime := run.runtime.Detached()
+	run.teardown = registerTeardown("container "+run.server.ContainerName, func() error {
+		err := stopContainer(run.log, runtime, run.server.ContainerName, run.configurationDirectory)
+
+		libraries := resolveLibraries(run.common)
+		if removeErr := removeWritableLibraries(run.log, run.server, libraries, run.configurationDirectory); err == nil {
+			err = removeErr
+		}
+
+		return err
+	})
+
+	plugin := initialPlugin(run.server)
+	run.configurationDirectory, err = startContainer(run.log, run.runtime, run.server, resolveLibraries(run.common), plugin)
+	if err != nil {
+		return fmt.Errorf("failed to start container: %w", err)
+	}
+
+	// Wait for the container to fully start
+	return waitForServerReady(run)
+}
+
+// Completes the startup wizard on a local server and restarts it.
+func stageSetup(run *serverRun) error {
+	if !run.server.Docker {
+		return errSkipStage
+	}
+
+	run.log.Infof("Setting up container")
+	SetupServer(run.log, run.server.Address, containerPassword, resolveLibraries(run.common))
+
+	// Restart the container and wait for it to come back up
+	if err := run.runtime.Restart(run.server.ContainerName); err != nil {
+		return fmt.Errorf("failed to restart container: %w", err)
+	}
+
+	time.Sleep(time.Second)
+	return waitForServerReady(run)
+}
+
+// Gets an API key.
+func stageLogin(run *serverRun) error {
+	run.apiKey = login(run.log, run.server)
+	registerSecret(run.apiKey)
+
+	// The plugin can only be checked once logged in. If it is installed later, it is checked after installing it.
+	if run.server.Docker && initialPlugin(run.server) != "" {
+		return waitForServerReady(run)
+	}
+
+	return nil
+}
+
+// Rescans the library if this is a server that we just setup.
+func stageScan(run *serverRun) error {
+	if !run.server.Docker {
+		return errSkipStage
+	}
+
+	run.log.Infof("Rescanning library")
+
+	sendRequest(
+		run.log,
+		run.server.Address+"/ScheduledTasks/Running/7738148ffcd07979c7ceb148e06b3aed?api_key="+run.apiKey,
+		"POST",
+		"")
+
+	// TODO: poll for task completion
+	time.Sleep(10 * time.Second)
+
+	return nil
+}
+
+// Returns the path that a server's analysis report is saved to. Comments are unique, so servers without a comment are
+// named after their index to keep their reports from overwriting each other.
+func analysisReportPath(run *serverRun) string {
+	name := run.server.Comment
+	if name == "" {
+		name = fmt.Sprintf("unnamed-%d", run.index)
+	}
+
+	return fmt.Sprintf("reports/%s-%d.json", name, run.start.Unix())
+}
+
+// Returns a verifier command with the provided arguments. The verifier logs in the same format and at the same level
+// as the wrapper.
+func verifierCommand(args ...string) Command {
+	return Command{
+		Program: "./verifier/verifier",
+		Args:    append(args, loggingArguments()...),
+	}
+}
+
+// Returns the verifier command used to analyze all episodes and save the report.
+func analyzeCommand(run *serverRun) Command {
+	return verifierCommand(
+		"-address", run.server.Address,
+		"-key", run.apiKey,
+		"-o", run.reportPath)
+}
+
+// Returns the verifier command used to collect diagnostics after analysis failed.
+func diagnosticsCommand(run *serverRun) Command {
+	command := verifierCommand(
+		"-address", run.server.Address,
+		"-key", run.apiKey,
+		"-diagnostics",
+		"-o", run.reportPath)
+	command.Note = "if analysis fails"
+
+	return command
+}
+
+// Analyzes all episodes and saves the report. The resource usage of local containers is sampled during analysis and
+// added to the report. If analysis fails, diagnostics are collected from the server.
+func stageAnalyze(run *serverRun) error {
+	run.reportPath = analysisReportPath(run)
+
+	var stopSampling func() (ResourceUsage, error)
+	if run.server.Docker {
+		stopSampling = startResourceSampling(run)
+	}
+
+	run.log.Infof("Analyzing episodes")
+	result := analyzeCommand(run).Run(ProgramOptions{Log: run.log, Timeout: 5 * time.Minute})
+
+	if stopSampling != nil {
+		usage, err := stopSampling()
+		recordResources(run, usage, err, result.Success())
+	}
+
+	if result.Success() {
+		return nil
+	}
+
+	run.log.Errorf("Analysis failed, collecting diagnostics")
+	diagnosticsCommand(run).Run(ProgramOptions{Log: run.log, Timeout: time.Minute})
+
+	return result.Err
+}
+
+// Logs the sampled resource usage, includes it in the stage details and, if the report was saved, adds it to the
+// report.
+func recordResources(run *serverRun, usage ResourceUsage, err error, saved bool) {
+	if err != nil {
+		run.log.Warnf("Unable to sample resource usage: %s", err)
+	}
+
+	if len(usage.Samples) == 0 {
+		return
+	}
+
+	run.log.Infof("Resource usage: %s", usage)
+	run.details = usage.String()
+
+	if !saved {
+		return
+	}
+
+	if err := addResourcesToReport(run.reportPath, usage); err != nil {
+		run.log.Warnf("Unable to add resource usage to report: %s", err)
+	}
+}
+
+// Returns the path that the comparison of a server's report against its baseline is saved to.
+func comparisonPath(run *serverRun) string {
+	return strings.TrimSuffix(run.reportPath, ".json") + ".html"
+}
+
+// Returns the verifier command used to compare a server's report against a baseline.
+func compareCommand(run *serverRun, baseline string) Command {
+	return verifierCommand(comparisonArguments(baseline, run.reportPath, comparisonPath(run), run.server.Thresholds)...)
+}
+
+// Compares the analysis results against the server's baseline report, if one is configured.
+func stageCompare(run *serverRun) error {
+	baseline, err := resolveBaseline(run.server, run.reportPath)
+	if err != nil {
+		return err
+	} else if baseline == "" {
+		return errSkipStage
+	}
+
+	output := comparisonPath(run)
+
+	run.log.Infof("Comparing against baseline %s", baseline)
+	result := compareCommand(run, baseline).Run(ProgramOptions{Log: run.log, Timeout: time.Minute})
+
+	if result.Success() {
+		run.details = fmt.Sprintf("passed, see %s", output)
+		return nil
+	}
+
+	run.details = fmt.Sprintf("failed, see %s", output)
+	return result.Err
+}
+
+// Returns the verifier command used to check the support bundle.
+func verifyCommand(run *serverRun) Command {
+	args := []string{"-address", run.server.Address, "-key", run.apiKey, "-bundle"}
+	if run.server.Docker && installedVersion != "" {
+		args = append(args, "-plugin-version", installedVersion)
+	}
+
+	return verifierCommand(args...)
+}
+
+// Checks the support bundle for warnings and, if the plugin was installed by us, the correct version.
+func stageVerify(run *serverRun) error {
+	run.log.Infof("Checking support bundle")
+	return verifyCommand(run).Run(ProgramOptions{Log: run.log, Timeout: 30 * time.Second}).Err
+}
+
+// Runs all requested Selenium tests.
+func stageUITests(run *serverRun) error {
+	return seleniumCommand(run).Run(ProgramOptions{Log: run.log, Timeout: time.Minute}).Err
+}
+
+// Returns the command used to run all requested Selenium tests.
+func seleniumCommand(run *serverRun) Command {
+	server := run.server
+
+	// Setup base Selenium arguments
+	seleniumArgs := []string{
+		"-u", // force stdout to be unbuffered
+		"main.py",
+		"-host", server.Address,
+		"-user", server.Username,
+		"-pass", server.Password,
+		"-name", run.common.Episode}
+
+	// Append all requested Selenium tests
+	seleniumArgs = append(seleniumArgs, "--tests")
+	seleniumArgs = append(seleniumArgs, server.Tests...)
+
+	// Append all requested browsers
+	seleniumArgs = append(seleniumArgs, "--browsers")
+	seleniumArgs = append(seleniumArgs, server.Browsers...)
+
+	return Command{Program: "python3", Args: seleniumArgs, Dir: "selenium"}
+}
+
+// Stops a local server's container and deletes its configuration directory.
+func stageTeardown(run *serverRun) error {
+	if !run.server.Docker {
+		return errSkipStage
+	}
+
+	// If the container was never started, there is nothing to cleanup
+	if run.teardown == 0 {
+		return errSkipStage
+	}
+
+	return runTeardown(run.teardown)
+}
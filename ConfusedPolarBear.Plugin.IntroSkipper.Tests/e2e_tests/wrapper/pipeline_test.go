@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunStages(t *testing.T) {
+	captureLog(t)
+
+	var ran []string
+	stage := func(name string, err error) Stage {
+		return Stage{Name: name, Run: func(*serverRun) error {
+			ran = append(ran, name)
+			return err
+		}}
+	}
+
+	stages := []Stage{
+		stage("first", nil),
+		stage("not applicable", errSkipStage),
+		{Name: "panics", Run: func(*serverRun) error { panic("boom") }},
+		stage("after failure", nil),
+		{Name: "cleanup", Always: true, Run: func(*serverRun) error {
+			ran = append(ran, "cleanup")
+			return nil
+		}},
+	}
+
+	result := runStages(&serverRun{server: Server{Comment: "test"}, log: rootLog}, stages)
+
+	if result.Passed() {
+		t.Error("result should not pass when a stage panics")
+	}
+
+	if strings.Join(ran, ",") != "first,not applicable,cleanup" {
+		t.Errorf("incorrect stages were run: %v", ran)
+	}
+
+	expected := []StageStatus{StagePassed, StageSkipped, StageFailed, StageSkipped, StagePassed}
+	for i, stage := range result.Stages {
+		if stage.Status != expected[i] {
+			t.Errorf("stage %s has status %s, expected %s", stage.Name, stage.Status, expected[i])
+		}
+	}
+
+	if err := result.Stages[2].Err; err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("panic was not recorded: %v", err)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	results := []ServerResult{
+		{Name: "passing", Stages: []StageResult{{Name: "login", Status: StagePassed}}},
+		{Name: "failing", Stages: []StageResult{
+			{Name: "analyze", Status: StageFailed, Err: errors.New("verifier failed")},
+			{Name: "verify", Status: StageSkipped, Err: errors.New("an earlier stage failed")},
+		}},
+	}
+
+	var table bytes.Buffer
+	printSummary(&table, results)
+	if !strings.Contains(table.String(), "verifier failed") {
+		t.Errorf("summary does not include stage errors: %s", table.String())
+	}
+
+	report := junitReport(results)
+	if report.Tests != 3 || report.Failures != 1 || report.Skipped != 1 || len(report.Suites) != 2 {
+		t.Errorf("incorrect JUnit totals: %+v", report)
+	}
+
+	marshalled, err := xml.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(marshalled), `<failure message="verifier failed">`) {
+		t.Errorf("JUnit report does not include the failure: %s", marshalled)
+	}
+}
+
+func TestAnalysisReportPath(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+
+	named := analysisReportPath(&serverRun{index: 0, server: Server{Comment: "10.8.9"}, start: start})
+	first := analysisReportPath(&serverRun{index: 1, start: start})
+	second := analysisReportPath(&serverRun{index: 2, start: start})
+
+	if named != "reports/10.8.9-1700000000.json" {
+		t.Errorf("incorrect report path: %s", named)
+	}
+
+	if first == second {
+		t.Errorf("servers without a comment share the report %s", first)
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// Everything that will be done in a run. Used both to test servers and to print what would be done in a dry run.
+type Plan struct {
+	Common Common
+
+	// Servers to test, in configuration order. Skipped servers are not included.
+	Servers []ServerPlan
+
+	// Serve the plugin from a local plugin repository while testing.
+	Repository bool
+}
+
+// A server to test and the stages used to test it.
+type ServerPlan struct {
+	// Index of the server in the configuration, after matrices were expanded.
+	Index int
+
+	Server Server
+	Stages []Stage
+}
+
+// Builds the plan for testing every server in a validated configuration.
+func buildPlan(config Configuration) Plan {
+	plan := Plan{
+		Common:     config.Common,
+		Repository: usesRepository(config.Servers),
+	}
+
+	for i, server := range config.Servers {
+		if server.Skip {
+			continue
+		}
+
+		plan.Servers = append(plan.Servers, ServerPlan{Index: i, Server: server, Stages: stagesFor(server)})
+	}
+
+	return plan
+}
+
+// Fills in the values which are only known once a server is being tested with placeholders.
+func prepareDryRun(run *serverRun) {
+	if run.server.Docker {
+		run.server.ContainerName = localContainerName(run)
+		run.server.Address = fmt.Sprintf("http://%s:PORT", containerAddress)
+		run.configurationDirectory = path.Join(configurationRoot, "jf-e2e-RANDOM")
+	}
+
+	run.apiKey = "API_KEY"
+	run.reportPath = analysisReportPath(run)
+}
+
+// Prints every server and stage in the plan along with the external commands that each stage would run, with all
+// credentials redacted. Nothing is executed.
+func printPlan(w io.Writer, plan Plan, start time.Time) error {
+	runtime, err := newCLIRuntime(plan.Common.Runtime, rootLog)
+	if err != nil {
+		return err
+	}
+
+	printCommands := func(commands []Command) {
+		for _, command := range commands {
+			if command.Note != "" {
+				fmt.Fprintf(w, "    $ %s (%s)\n", command, command.Note)
+			} else {
+				fmt.Fprintf(w, "    $ %s\n", command)
+			}
+		}
+	}
+
+	fmt.Fprintln(w, "[+] Dry run, nothing will be executed")
+	fmt.Fprintln(w, "[+] Ports, configuration directories and API keys are shown as placeholders")
+	fmt.Fprintln(w)
+
+	if plan.Repository {
+		fmt.Fprintf(w, "[+] Serving plugin repository built from %s\n", manifestPath)
+	}
+
+	fmt.Fprintln(w, "[+] Starting Selenium")
+	printCommands([]Command{runtime.composeCommand("up", "-d")})
+	fmt.Fprintln(w)
+
+	fmt.Fprintf(w, "[+] Testing %d servers, up to %d at a time\n", len(plan.Servers), plan.Common.MaxParallelism)
+	for _, planned := range plan.Servers {
+		run := newServerRun(planned, plan.Common, start)
+		prepareDryRun(run)
+
+		fmt.Fprintf(w, "===== Server: %s =====\n", serverName(run.index, run.server))
+		if run.server.Docker {
+			fmt.Fprintf(w, "Image:    %s\n", run.server.Image)
+		} else {
+			fmt.Fprintf(w, "Address:  %s\n", run.server.Address)
+		}
+		fmt.Fprintf(w, "Browsers: %v\n", run.server.Browsers)
+		fmt.Fprintf(w, "Tests:    %v\n", run.server.Tests)
+		for _, checkpoint := range run.server.Checkpoints {
+			fmt.Fprintf(w, "Manual:   %s\n", describeCheckpoint(checkpoint))
+		}
+
+		for _, stage := range planned.Stages {
+			fmt.Fprintf(w, "  [+] Stage: %s\n", stage.Name)
+			if stage.Commands != nil {
+				printCommands(stage.Commands(run, runtime))
+			}
+		}
+
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintln(w, "[+] Stopping Selenium")
+	printCommands([]Command{runtime.composeCommand("down")})
+
+	return nil
+}
+
+// Describes the commands used to start a local server's container.
+func describeContainerStart(run *serverRun, runtime *cliRuntime) []Command {
+	if !run.server.Docker {
+		return nil
+	}
+
+	var commands []Command
+	if initialPlugin(run.server) != "" && isLSIOImage(run.server.Image) {
+		plugins := path.Dir(pluginDirectory(run.server, run.configurationDirectory))
+		commands = append(commands, runtime.chownCommand("911:911", plugins))
+	}
+
+	libraries := resolveLibraries(run.common)
+	if edlEnabled(run.server) {
+		libraries = writableLibraries(libraries, run.configurationDirectory)
+	}
+
+	// The host port is only allocated when the container is started
+	command := runtime.runCommand(containerSpec(run.server, run.configurationDirectory, libraries))
+	for i, arg := range command.Args {
+		if strings.HasPrefix(arg, "0:") {
+			command.Args[i] = "PORT" + strings.TrimPrefix(arg, "0")
+		}
+	}
+
+	return append(commands, command)
+}
+
+// Describes the command used to restart a local server after completing the startup wizard.
+func describeSetup(run *serverRun, runtime *cliRuntime) []Command {
+	if !run.server.Docker {
+		return nil
+	}
+
+	return []Command{runtime.restartCommand(run.server.ContainerName)}
+}
+
+// Describes the command used to load the plugin after installing it from the local plugin repository.
+func describeInstall(run *serverRun, runtime *cliRuntime) []Command {
+	if run.server.Install != installRepository {
+		return nil
+	}
+
+	return []Command{runtime.restartCommand(run.server.ContainerName)}
+}
+
+// Describes the commands used to analyze episodes and sample the resource usage of local containers.
+func describeAnalyze(run *serverRun, runtime *cliRuntime) []Command {
+	commands := []Command{analyzeCommand(run)}
+
+	if run.server.Docker {
+		stats := runtime.statsCommand(run.server.ContainerName)
+		stats.Note = fmt.Sprintf("every %s during analysis", resourceSampleInterval)
+		commands = append(commands, stats)
+	}
+
+	return append(commands, diagnosticsCommand(run))
+}
+
+// Describes the command used to compare the report against the server's baseline, if one is configured.
+func describeCompare(run *serverRun, runtime *cliRuntime) []Command {
+	baseline, err := resolveBaseline(run.server, run.reportPath)
+	if err != nil {
+		return []Command{{Program: "./verifier/verifier", Note: fmt.Sprintf("fails: %s", err)}}
+	} else if baseline == "" {
+		return nil
+	}
+
+	return []Command{compareCommand(run, baseline)}
+}
+
+// Describes the command used to check the support bundle.
+func describeVerify(run *serverRun, runtime *cliRuntime) []Command {
+	return []Command{verifyCommand(run)}
+}
+
+// Describes the command used to run the Selenium tests.
+func describeUITests(run *serverRun, runtime *cliRuntime) []Command {
+	return []Command{seleniumCommand(run)}
+}
+
+// Describes the command used to save a local server's container log.
+func describeArtifacts(run *serverRun, runtime *cliRuntime) []Command {
+	if !run.server.Docker || artifactsRoot == "" {
+		return nil
+	}
+
+	return []Command{runtime.logsCommand(run.server.ContainerName)}
+}
+
+// Describes the command used to stop a local server's container.
+func describeTeardown(run *serverRun, runtime *cliRuntime) []Command {
+	if !run.server.Docker {
+		return nil
+	}
+
+	return []Command{runtime.stopCommand(run.server.ContainerName)}
+}
+
+// Describes the commands used to replace the old plugin and restart the server.
+func describeUpgrade(run *serverRun, runtime *cliRuntime) []Command {
+	var commands []Command
+	if isLSIOImage(run.server.Image) {
+		plugins := path.Dir(pluginDirectory(run.server, run.configurationDirectory))
+		commands = append(commands, runtime.chownCommand("911:911", plugins))
+	}
+
+	return append(commands, runtime.restartCommand(run.server.ContainerName))
+}
+
+// Describes the commands used to compare the timestamps before and after upgrading.
+func describeCompareUpgrade(run *serverRun, runtime *cliRuntime) []Command {
+	save, compare := compareUpgradeCommands(run)
+	return []Command{save, compare}
+}
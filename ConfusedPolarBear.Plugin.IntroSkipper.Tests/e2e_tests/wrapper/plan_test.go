@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildPlan(t *testing.T) {
+	config := Configuration{
+		Common: Common{MaxParallelism: 2},
+		Servers: []Server{
+			{Comment: "local", Image: "jellyfin/jellyfin", Docker: true},
+			{Comment: "skipped", Address: "http://127.0.0.1:8096", Skip: true},
+			{Comment: "upgrade", Image: "jellyfin/jellyfin", Docker: true, Scenario: scenarioUpgrade, UpgradeFrom: "old.dll"},
+			{Comment: "repository", Image: "jellyfin/jellyfin", Docker: true, Install: installRepository},
+		},
+	}
+
+	plan := buildPlan(config)
+	if len(plan.Servers) != 3 || !plan.Repository {
+		t.Fatalf("incorrect plan: %+v", plan)
+	}
+
+	// Indexes refer to the configuration so that output is named consistently
+	if plan.Servers[1].Index != 2 || plan.Servers[1].Stages[6].Name != "upgrade" {
+		t.Errorf("incorrect upgrade plan: %+v", plan.Servers[1])
+	}
+}
+
+func TestPrintPlan(t *testing.T) {
+	setupContainerTest(t)
+
+	oldPassword, oldAddress := containerPassword, containerAddress
+	t.Cleanup(func() { containerPassword, containerAddress = oldPassword, oldAddress })
+	containerPassword, containerAddress = "hunter2", "192.0.2.1"
+
+	config := Configuration{
+		Common: Common{Library: "/srv/TV", Episode: "Pilot", Runtime: "podman", MaxParallelism: 1},
+		Servers: []Server{
+			{
+				Comment:  "local",
+				Image:    "lscr.io/linuxserver/jellyfin",
+				Username: "admin",
+				Password: containerPassword,
+				Browsers: []string{"chrome"},
+				Tests:    []string{"settings"},
+				Docker:   true,
+			},
+			{
+				Comment:  "remote",
+				Address:  "https://jellyfin.example.com",
+				Username: "admin",
+				Password: "remote-password",
+				Browsers: []string{"firefox"},
+				Tests:    []string{"skip_button"},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := printPlan(&buf, buildPlan(config), time.Unix(1000, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	output := buf.String()
+
+	for _, expected := range []string{
+		"$ podman-compose up -d",
+		"[+] Testing 2 servers, up to 1 at a time",
+		"$ podman unshare chown -R 911:911 " + configurationRoot + "/jf-e2e-RANDOM/data/plugins",
+		"$ podman run --detach --rm --name jf-e2e-",
+		"-p PORT:8096",
+		"--format '{{.CPUPerc}}\t{{.MemUsage}}'",
+		"-v /srv/TV:/media:ro lscr.io/linuxserver/jellyfin",
+		"$ ./verifier/verifier -address http://192.0.2.1:PORT -key REDACTED -o reports/local-1000.json",
+		"(every 5s during analysis)",
+		"$ (cd selenium && python3 -u main.py -host https://jellyfin.example.com -user REDACTED -pass REDACTED",
+		"  [+] Stage: teardown\n    $ podman stop jf-e2e-",
+		"$ podman-compose down",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("plan does not contain %q:\n%s", expected, output)
+		}
+	}
+
+	for _, secret := range []string{"hunter2", "remote-password", "API_KEY"} {
+		if strings.Contains(output, secret) {
+			t.Errorf("plan contains secret %q:\n%s", secret, output)
+		}
+	}
+
+	// Remote servers are never restarted or stopped
+	remote := output[strings.Index(output, "===== Server: remote"):]
+	if strings.Contains(remote, "podman restart") || strings.Contains(remote, "podman stop") {
+		t.Errorf("remote server is managed as a container:\n%s", remote)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"unicode/utf16"
+)
+
+// Reads the file version of a compiled plugin DLL from its version information resource.
+func pluginVersion(path string) (string, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return findVersionString(contents, "FileVersion")
+}
+
+// Searches a PE file for a String structure with the provided key in its version information resource and returns
+// the value. String structures are laid out as:
+//
+//	WORD  wLength
+//	WORD  wValueLength
+//	WORD  wType
+//	WCHAR szKey[]   (null terminated)
+//	WORD  Padding[] (aligns the value to a 32 bit boundary)
+//	WCHAR Value[]   (null terminated)
+func findVersionString(contents []byte, key string) (string, error) {
+	needle := encodeUTF16(key + "\x00")
+
+	keyStart := bytes.Index(contents, needle)
+	if keyStart < 6 {
+		return "", errors.New("unable to find " + key + " in version information")
+	}
+
+	// Skip the header and key, then align to the next 32 bit boundary relative to the start of the structure
+	structStart := keyStart - 6
+	offset := keyStart + len(needle)
+	if padding := (offset - structStart) % 4; padding != 0 {
+		offset += 4 - padding
+	}
+
+	var value []uint16
+	for ; offset+1 < len(contents); offset += 2 {
+		c := binary.LittleEndian.Uint16(contents[offset:])
+		if c == 0 {
+			return string(utf16.Decode(value)), nil
+		}
+
+		value = append(value, c)
+	}
+
+	return "", errors.New("unterminated " + key + " in version information")
+}
+
+// Encodes a string as little endian UTF-16.
+func encodeUTF16(raw string) []byte {
+	var encoded []byte
+
+	for _, c := range utf16.Encode([]rune(raw)) {
+		encoded = append(encoded, byte(c), byte(c>>8))
+	}
+
+	return encoded
+}
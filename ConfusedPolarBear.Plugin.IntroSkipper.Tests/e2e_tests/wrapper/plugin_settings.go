@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// GUID of the Intro Skipper plugin.
+const pluginGuid = "c83d86bb-a1e0-4c35-a113-e2101cf4ee6b"
+
+// Merges the provided settings into the plugin's current configuration. Settings which are not part of the current
+// configuration are rejected, as the plugin would silently ignore them.
+func mergePluginConfiguration(live, settings map[string]interface{}) (map[string]interface{}, error) {
+	merged := make(map[string]interface{}, len(live))
+	for key, value := range live {
+		merged[key] = value
+	}
+
+	var unknown []string
+	for key, value := range settings {
+		if _, ok := live[key]; !ok {
+			unknown = append(unknown, key)
+			continue
+		}
+
+		merged[key] = value
+	}
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown plugin settings: %s", strings.Join(unknown, ", "))
+	}
+
+	return merged, nil
+}
+
+// Returns a description of every setting which does not have the expected value in the plugin's configuration.
+func verifyPluginConfiguration(actual, settings map[string]interface{}) []string {
+	var keys []string
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var mismatches []string
+	for _, key := range keys {
+		// Round trip the expected value through JSON so numbers are compared as float64, like the actual value
+		expected := settings[key]
+		if raw, err := json.Marshal(expected); err == nil {
+			json.Unmarshal(raw, &expected)
+		}
+
+		if !reflect.DeepEqual(actual[key], expected) {
+			mismatches = append(mismatches, fmt.Sprintf("%s is %v, expected %v", key, actual[key], expected))
+		}
+	}
+
+	return mismatches
+}
+
+// Applies the server's plugin settings and checks that they took effect.
+func stageConfigure(run *serverRun) error {
+	settings := run.server.PluginConfiguration
+	if len(settings) == 0 {
+		return errSkipStage
+	}
+
+	endpoint := fmt.Sprintf("%s/Plugins/%s/Configuration?api_key=%s", run.server.Address, pluginGuid, run.apiKey)
+
+	run.log.Infof("Getting plugin configuration")
+	var live map[string]interface{}
+	if err := apiJSON(run.log, "GET", endpoint, "", &live); err != nil {
+		return fmt.Errorf("failed to get plugin configuration: %w", err)
+	}
+
+	merged, err := mergePluginConfiguration(live, settings)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(merged)
+	if err != nil {
+		return err
+	}
+
+	run.log.Infof("Updating plugin configuration")
+	if _, err := apiRequest(run.log, "POST", endpoint, string(body)); err != nil {
+		return fmt.Errorf("failed to update plugin configuration: %w", err)
+	}
+
+	// Read the configuration back to ensure that every setting was saved
+	var updated map[string]interface{}
+	if err := apiJSON(run.log, "GET", endpoint, "", &updated); err != nil {
+		return fmt.Errorf("failed to get plugin configuration: %w", err)
+	}
+
+	if mismatches := verifyPluginConfiguration(updated, settings); len(mismatches) > 0 {
+		return fmt.Errorf("plugin settings were not saved: %s", strings.Join(mismatches, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMergePluginConfiguration(t *testing.T) {
+	live := map[string]interface{}{"AnalyzeSeasonZero": false, "MaxParallelism": 2.0}
+
+	merged, err := mergePluginConfiguration(live, map[string]interface{}{"AnalyzeSeasonZero": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if merged["AnalyzeSeasonZero"] != true || merged["MaxParallelism"] != 2.0 || live["AnalyzeSeasonZero"] != false {
+		t.Errorf("settings were merged incorrectly: %v", merged)
+	}
+
+	if _, err := mergePluginConfiguration(live, map[string]interface{}{"AnalyseSeasonZero": true}); err == nil ||
+		err.Error() != "unknown plugin settings: AnalyseSeasonZero" {
+		t.Errorf("unknown setting was not rejected: %v", err)
+	}
+}
+
+func TestStageConfigure(t *testing.T) {
+	captureLog(t)
+
+	// Plugin which ignores changes to EdlAction
+	configuration := map[string]interface{}{"AnalyzeSeasonZero": false, "MaxParallelism": 2, "EdlAction": "None"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/Plugins/"+pluginGuid+"/Configuration", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			body, _ := io.ReadAll(r.Body)
+
+			var updated map[string]interface{}
+			if err := json.Unmarshal(body, &updated); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			updated["EdlAction"] = configuration["EdlAction"]
+			configuration = updated
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		json.NewEncoder(w).Encode(configuration)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	run := &serverRun{
+		server: Server{Address: server.URL, PluginConfiguration: map[string]interface{}{
+			"AnalyzeSeasonZero": true,
+			"MaxParallelism":    4,
+		}},
+		log: rootLog,
+	}
+
+	if err := stageConfigure(run); err != nil {
+		t.Fatal(err)
+	}
+
+	if configuration["AnalyzeSeasonZero"] != true || configuration["MaxParallelism"] != 4.0 {
+		t.Errorf("configuration was not updated: %v", configuration)
+	}
+
+	// Settings which do not take effect must fail the stage
+	run.server.PluginConfiguration = map[string]interface{}{"EdlAction": "Intro"}
+	if err := stageConfigure(run); err == nil || !strings.Contains(err.Error(), "EdlAction is None, expected Intro") {
+		t.Errorf("setting which did not take effect was not reported: %v", err)
+	}
+
+	run.server.PluginConfiguration = nil
+	if err := stageConfigure(run); err != errSkipStage {
+		t.Errorf("stage should be skipped without any settings: %v", err)
+	}
+}
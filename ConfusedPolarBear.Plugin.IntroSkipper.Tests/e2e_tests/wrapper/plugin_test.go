@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestFindVersionString(t *testing.T) {
+	// Build a String structure preceded by some unrelated data
+	contents := []byte("MZ\x90\x00unrelated")
+	contents = append(contents, 0x2c, 0x00, 0x08, 0x00, 0x01, 0x00) // header
+	contents = append(contents, encodeUTF16("FileVersion\x00")...)
+	contents = append(contents, 0x00, 0x00) // padding
+	contents = append(contents, encodeUTF16("0.1.8.0\x00")...)
+
+	// Pad the start of the structure to a 32 bit boundary
+	contents = append(make([]byte, 3), contents...)
+
+	version, err := findVersionString(contents, "FileVersion")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if version != "0.1.8.0" {
+		t.Errorf("incorrect version %q", version)
+	}
+
+	if _, err := findVersionString(contents, "ProductVersion"); err == nil {
+		t.Error("expected an error for a missing key")
+	}
+}
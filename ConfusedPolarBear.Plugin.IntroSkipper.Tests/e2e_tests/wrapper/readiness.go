@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Defaults used for readiness settings which are not configured.
+const (
+	defaultReadinessTimeout     = time.Minute
+	defaultReadinessInterval    = time.Second
+	defaultReadinessMaxInterval = 10 * time.Second
+	defaultReadinessBackoff     = 1.5
+)
+
+// Minimum time a single readiness check is given to complete, even if it is made right before the timeout elapses.
+const minimumReadinessCheck = time.Second
+
+// Parsed readiness settings, with defaults applied.
+type readinessProbe struct {
+	timeout     time.Duration
+	interval    time.Duration
+	maxInterval time.Duration
+	backoff     float64
+}
+
+// Parses the readiness settings and applies defaults. Settings are validated when the configuration is loaded.
+func (r Readiness) probe() readinessProbe {
+	probe := readinessProbe{
+		timeout:     defaultReadinessTimeout,
+		interval:    defaultReadinessInterval,
+		maxInterval: defaultReadinessMaxInterval,
+		backoff:     defaultReadinessBackoff,
+	}
+
+	parse := func(raw string, value *time.Duration) {
+		if parsed, err := time.ParseDuration(raw); err == nil && parsed > 0 {
+			*value = parsed
+		}
+	}
+
+	parse(r.Timeout, &probe.timeout)
+	parse(r.Interval, &probe.interval)
+	parse(r.MaxInterval, &probe.maxInterval)
+
+	if r.Backoff >= 1 {
+		probe.backoff = r.Backoff
+	}
+
+	if probe.maxInterval < probe.interval {
+		probe.maxInterval = probe.interval
+	}
+
+	return probe
+}
+
+// Returns how long to wait before the next attempt.
+func (p readinessProbe) next(interval time.Duration) time.Duration {
+	interval = time.Duration(float64(interval) * p.backoff)
+	if interval > p.maxInterval {
+		return p.maxInterval
+	}
+
+	return interval
+}
+
+// Validates the readiness settings.
+func validateReadiness(readiness Readiness) configurationErrors {
+	var problems configurationErrors
+
+	add := func(field, format string, args ...interface{}) {
+		problems = append(problems, fmt.Errorf("common.readiness.%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+
+	durations := []struct{ field, raw string }{
+		{"timeout", readiness.Timeout},
+		{"interval", readiness.Interval},
+		{"max_interval", readiness.MaxInterval},
+	}
+
+	for _, setting := range durations {
+		if setting.raw == "" {
+			continue
+		}
+
+		if duration, err := time.ParseDuration(setting.raw); err != nil {
+			add(setting.field, "invalid duration %q (expected a value such as 30s or 5m)", setting.raw)
+		} else if duration <= 0 {
+			add(setting.field, "must be positive")
+		}
+	}
+
+	if readiness.Backoff != 0 && readiness.Backoff < 1 {
+		add("backoff", "must be at least 1")
+	}
+
+	return problems
+}
+
+// Plugin as returned by the /Plugins endpoint.
+type installedPlugin struct {
+	Id      string
+	Name    string
+	Version string
+	Status  string
+}
+
+// Checks that Intro Skipper is installed and active.
+func checkPluginActive(ctx context.Context, run *serverRun) error {
+	raw, err := apiRequestContext(ctx, run.log, "GET", fmt.Sprintf("%s/Plugins?api_key=%s", run.server.Address, run.apiKey), "")
+	if err != nil {
+		return err
+	}
+
+	var plugins []installedPlugin
+	if err := json.Unmarshal(raw, &plugins); err != nil {
+		return fmt.Errorf("unable to parse plugins: %w", err)
+	}
+
+	// Jellyfin formats GUIDs without dashes
+	guid := strings.ReplaceAll(pluginGuid, "-", "")
+
+	var statuses []string
+	for _, plugin := range plugins {
+		if !strings.EqualFold(strings.ReplaceAll(plugin.Id, "-", ""), guid) {
+			continue
+		}
+
+		if plugin.Status == "Active" {
+			return nil
+		}
+
+		statuses = append(statuses, fmt.Sprintf("%s %s is %s", plugin.Name, plugin.Version, plugin.Status))
+	}
+
+	if len(statuses) == 0 {
+		return errors.New("Intro Skipper is not installed")
+	}
+
+	return errors.New(strings.Join(statuses, ", "))
+}
+
+// Checks that the server responds to requests and, once logged in, that the plugin is active. The plugin list
+// requires authentication, so the plugin is not checked before an API key was obtained.
+func checkReadiness(ctx context.Context, run *serverRun) error {
+	if _, err := apiRequestContext(ctx, run.log, "GET", run.server.Address+"/System/Info/Public", ""); err != nil {
+		return err
+	}
+
+	if run.apiKey == "" {
+		return nil
+	}
+
+	return checkPluginActive(ctx, run)
+}
+
+// Waits for a server to become ready, polling at the configured interval and backing off after every failed attempt
+// until the configured timeout elapses.
+func waitForServerReady(run *serverRun) error {
+	probe := run.common.Readiness.probe()
+	deadline := time.Now().Add(probe.timeout)
+	interval := probe.interval
+
+	if run.apiKey == "" {
+		run.log.Infof("Waiting for server to finish starting")
+	} else {
+		run.log.Infof("Waiting for server to finish starting and load the plugin")
+	}
+
+	for attempt := 1; ; attempt++ {
+		// Never sleep past the deadline, so that the last attempt is made right before giving up
+		if remaining := time.Until(deadline); interval > remaining {
+			interval = remaining
+		}
+
+		// Sleep in between requests, giving up as soon as the wrapper is interrupted
+		select {
+		case <-runContext.Done():
+			return errors.New("interrupted while waiting for server to start")
+
+		case <-time.After(interval):
+		}
+
+		// A server which accepts connections but never responds must not stall the check past the deadline
+		timeout := time.Until(deadline)
+		if timeout < minimumReadinessCheck {
+			timeout = minimumReadinessCheck
+		}
+
+		ctx, cancel := context.WithTimeout(runContext, timeout)
+		err := checkReadiness(ctx, run)
+		cancel()
+
+		if err == nil {
+			run.log.Debugf("Server was ready after %d attempts", attempt)
+			return nil
+		}
+
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("server was not ready within %s (%d attempts): %w", probe.timeout, attempt, err)
+		}
+
+		run.log.Debugf("Server is not ready: %s", err)
+		interval = probe.next(interval)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadinessProbe(t *testing.T) {
+	probe := Readiness{}.probe()
+	if probe.timeout != time.Minute || probe.interval != time.Second || probe.backoff != 1.5 {
+		t.Errorf("defaults were not applied: %+v", probe)
+	}
+
+	probe = Readiness{Interval: "2s", Backoff: 2, MaxInterval: "5s"}.probe()
+
+	var intervals []time.Duration
+	for interval := probe.interval; len(intervals) < 4; interval = probe.next(interval) {
+		intervals = append(intervals, interval)
+	}
+
+	if fmt.Sprint(intervals) != "[2s 4s 5s 5s]" {
+		t.Errorf("incorrect intervals: %v", intervals)
+	}
+}
+
+// Returns a server which is only ready after a few attempts. Until then, it is starting up and the plugin is
+// waiting for a restart.
+func readinessTestServer(t *testing.T, status string) *httptest.Server {
+	attempts := 0
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/System/Info/Public", func(w http.ResponseWriter, r *http.Request) {
+		if attempts++; attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+
+	mux.HandleFunc("/Plugins", func(w http.ResponseWriter, r *http.Request) {
+		current := status
+		if attempts < 4 {
+			current = "Restart"
+		}
+
+		fmt.Fprintf(w, `[
+			{"Name": "Playback Reporting", "Version": "12.0.0.0", "Id": "5c53438191a343cb907a35aa02eb9d2c", "Status": "Active"},
+			{"Name": "Intro Skipper", "Version": "0.1.8.0", "Id": "c83d86bba1e04c35a113e2101cf4ee6b", "Status": "%s"}
+		]`, current)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestWaitForServerReady(t *testing.T) {
+	captureLog(t)
+	server := readinessTestServer(t, "Active")
+
+	run := &serverRun{
+		server: Server{Address: server.URL},
+		common: Common{Readiness: Readiness{Timeout: "5s", Interval: "1ms"}},
+		log:    rootLog,
+	}
+
+	// Only the server is checked before logging in
+	if err := waitForServerReady(run); err != nil {
+		t.Fatal(err)
+	}
+
+	run.apiKey = "key"
+	if err := waitForServerReady(run); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWaitForServerReadyTimeout(t *testing.T) {
+	captureLog(t)
+	server := readinessTestServer(t, "Malfunctioned")
+
+	run := &serverRun{
+		server: Server{Address: server.URL},
+		common: Common{Readiness: Readiness{Timeout: "50ms", Interval: "1ms", MaxInterval: "5ms"}},
+		log:    rootLog,
+		apiKey: "key",
+	}
+
+	err := waitForServerReady(run)
+	if err == nil || !strings.Contains(err.Error(), "Intro Skipper 0.1.8.0 is Malfunctioned") {
+		t.Errorf("plugin status was not reported: %v", err)
+	}
+}
+
+func TestWaitForServerReadyUnresponsive(t *testing.T) {
+	captureLog(t)
+
+	// Accept connections but never respond
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	run := &serverRun{
+		server: Server{Address: server.URL},
+		common: Common{Readiness: Readiness{Timeout: "100ms", Interval: "1ms"}},
+		log:    rootLog,
+	}
+
+	start := time.Now()
+	err := waitForServerReady(run)
+
+	if err == nil || !strings.Contains(err.Error(), "not ready within 100ms") {
+		t.Errorf("unresponsive server was not reported: %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond+minimumReadinessCheck+time.Second {
+		t.Errorf("waited %s for an unresponsive server", elapsed)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Replacement for any secret found in output.
+const redacted = "REDACTED"
+
+// Secrets shorter than this are not registered, as masking them would garble unrelated output.
+const minimumSecretLength = 4
+
+// Passwords and API keys which are masked in all output.
+var secretRegistry struct {
+	sync.RWMutex
+	secrets []string
+}
+
+// Patterns which match credentials even if they were never registered. The first group of each pattern is kept and
+// the rest of the match is replaced.
+var secretPatterns = []*regexp.Regexp{
+	// Command line arguments, i.e. "-key abc"
+	regexp.MustCompile(`((?:^|\s)-(?:user|pass|key) )\S+`),
+
+	// URL query parameters, i.e. "?api_key=abc"
+	regexp.MustCompile(`(?i)(\b(?:api_key|apikey|token|password|pw)=)[^&\s"'#]+`),
+
+	// Headers, i.e. "X-Emby-Token: abc" or `Authorization: MediaBrowser Token="abc"`
+	regexp.MustCompile(`(?i)((?:X-Emby-Token|X-MediaBrowser-Token):\s*)\S+`),
+	regexp.MustCompile(`(?i)(Authorization:\s*(?:Bearer|Basic)\s+)\S+`),
+	regexp.MustCompile(`(?i)(\bToken=")[^"]*`),
+
+	// JSON properties, i.e. {"Pw":"abc"}
+	regexp.MustCompile(`(?i)("(?:Pw|Password|AccessToken|api_key|ApiKey|Token)"\s*:\s*")(?:[^"\\]|\\.)*`),
+}
+
+// Registers a secret so that it is masked in all output, including its URL and JSON encoded forms.
+func registerSecret(secret string) {
+	if len(secret) < minimumSecretLength {
+		return
+	}
+
+	forms := []string{secret, url.QueryEscape(secret), url.PathEscape(secret)}
+	if encoded, err := json.Marshal(secret); err == nil {
+		forms = append(forms, strings.Trim(string(encoded), `"`))
+	}
+
+	secretRegistry.Lock()
+	defer secretRegistry.Unlock()
+
+	for _, form := range forms {
+		if !contains(secretRegistry.secrets, form) {
+			secretRegistry.secrets = append(secretRegistry.secrets, form)
+		}
+	}
+
+	// Replace longer secrets first so that a secret containing another secret is fully masked
+	sort.Slice(secretRegistry.secrets, func(i, j int) bool {
+		return len(secretRegistry.secrets[i]) > len(secretRegistry.secrets[j])
+	})
+}
+
+// Masks every registered secret and anything which looks like a credential in text.
+func redact(text string) string {
+	secretRegistry.RLock()
+	for _, secret := range secretRegistry.secrets {
+		text = strings.ReplaceAll(text, secret, redacted)
+	}
+	secretRegistry.RUnlock()
+
+	for _, pattern := range secretPatterns {
+		text = pattern.ReplaceAllString(text, "${1}"+redacted)
+	}
+
+	return text
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Registers secrets for the duration of a test.
+func registerTestSecrets(t *testing.T, secrets ...string) {
+	secretRegistry.Lock()
+	old := append([]string(nil), secretRegistry.secrets...)
+	secretRegistry.Unlock()
+
+	t.Cleanup(func() {
+		secretRegistry.Lock()
+		secretRegistry.secrets = old
+		secretRegistry.Unlock()
+	})
+
+	for _, secret := range secrets {
+		registerSecret(secret)
+	}
+}
+
+func TestRedactArguments(t *testing.T) {
+	raw := "-key deadbeef -first second -user admin -third fourth -pass hunter2"
+	expected := "-key REDACTED -first second -user REDACTED -third fourth -pass REDACTED"
+
+	if actual := redact(raw); actual != expected {
+		t.Errorf("arguments were redacted incorrectly: %s", actual)
+	}
+
+	// Flags which merely end with a sensitive name are left alone
+	if actual := redact("--monkey business"); actual != "--monkey business" {
+		t.Errorf("unrelated argument was redacted: %s", actual)
+	}
+}
+
+func TestRedactRegisteredSecrets(t *testing.T) {
+	registerTestSecrets(t, "p@ss word\"1", "abc", "")
+
+	raw := `logged in with p@ss word"1 as p%40ss+word%221 or p@ss%20word%221 in {"value":"p@ss word\"1"}`
+	expected := `logged in with REDACTED as REDACTED or REDACTED in {"value":"REDACTED"}`
+
+	if actual := redact(raw); actual != expected {
+		t.Errorf("secret was redacted incorrectly: %s", actual)
+	}
+
+	// Short secrets are never registered
+	if actual := redact("abcdef"); actual != "abcdef" {
+		t.Errorf("short secret was redacted: %s", actual)
+	}
+}
+
+func TestRedactURLs(t *testing.T) {
+	raw := "GET http://127.0.0.1:8096/Items?api_key=abc123&userId=1 /Users?ApiKey=def#top password=swordfish"
+	expected := "GET http://127.0.0.1:8096/Items?api_key=REDACTED&userId=1 /Users?ApiKey=REDACTED#top password=REDACTED"
+
+	if actual := redact(raw); actual != expected {
+		t.Errorf("URL was redacted incorrectly: %s", actual)
+	}
+}
+
+func TestRedactHeaders(t *testing.T) {
+	raw := strings.Join([]string{
+		"X-Emby-Token: abc123",
+		"x-mediabrowser-token:def456",
+		"Authorization: Bearer ghi789",
+		`X-Emby-Authorization: MediaBrowser Client="E2E", Device="E2E", Token="jkl012"`,
+	}, "\n")
+
+	expected := strings.Join([]string{
+		"X-Emby-Token: REDACTED",
+		"x-mediabrowser-token:REDACTED",
+		"Authorization: Bearer REDACTED",
+		`X-Emby-Authorization: MediaBrowser Client="E2E", Device="E2E", Token="REDACTED"`,
+	}, "\n")
+
+	if actual := redact(raw); actual != expected {
+		t.Errorf("headers were redacted incorrectly:\n%s", actual)
+	}
+}
+
+func TestRedactJSON(t *testing.T) {
+	raw := `{"Username":"admin","Pw":"hunter2"} {"Name":"admin","Password": "a\"b"} {"AccessToken":"abc","ServerId":"1"}`
+	expected := `{"Username":"admin","Pw":"REDACTED"} {"Name":"admin","Password": "REDACTED"} {"AccessToken":"REDACTED","ServerId":"1"}`
+
+	if actual := redact(raw); actual != expected {
+		t.Errorf("JSON was redacted incorrectly: %s", actual)
+	}
+}
+
+func TestRedactLogger(t *testing.T) {
+	buf := captureLog(t)
+	registerTestSecrets(t, "deadbeef")
+
+	newLogger("test").Printf("  [+] GET /System/Info?api_key=deadbeef 200\n  [+] Key: deadbeef\n")
+
+	expected := "[test]   [+] GET /System/Info?api_key=REDACTED 200\n[test]   [+] Key: REDACTED\n"
+	if buf.String() != expected {
+		t.Errorf("logged output was not redacted: %q", buf.String())
+	}
+}
+
+func TestRedactProgramOutput(t *testing.T) {
+	buf := captureLog(t)
+	registerTestSecrets(t, "deadbeef")
+
+	var tee bytes.Buffer
+	result := RunProgram("sh", []string{"-c", "echo \"$0\"; echo 'key is deadbeef' >&2", "-key deadbeef"}, ProgramOptions{
+		Log:     newLogger("test"),
+		Timeout: 5 * time.Second,
+		Tee:     &tee,
+	})
+
+	if !result.Success() {
+		t.Fatalf("program failed: %v", result.Err)
+	}
+
+	for name, output := range map[string]string{"log": buf.String(), "tee": tee.String()} {
+		if strings.Contains(output, "deadbeef") {
+			t.Errorf("%s contains secret: %q", name, output)
+		}
+	}
+
+	if !strings.Contains(buf.String(), "[test]     ! key is REDACTED\n") {
+		t.Errorf("program output was not logged: %q", buf.String())
+	}
+
+	// Captured output is used to parse results and is left as is
+	if result.Stdout != "-key deadbeef\n" {
+		t.Errorf("captured output was redacted: %q", result.Stdout)
+	}
+}
+
+func TestRedactSummary(t *testing.T) {
+	registerTestSecrets(t, "deadbeef")
+
+	results := []ServerResult{{
+		Name: "local",
+		Stages: []StageResult{
+			{Name: "login", Status: StagePassed, Details: "API key deadbeef"},
+			{Name: "scan", Status: StageFailed, Err: errors.New("GET /Library/Refresh?api_key=deadbeef: 401")},
+		},
+	}}
+
+	var buf bytes.Buffer
+	printSummary(&buf, results)
+
+	report := junitReport(results)
+	suite := report.Suites[0]
+
+	for _, output := range []string{buf.String(), suite.TestCases[0].SystemOut, suite.TestCases[1].Failure.Message} {
+		if strings.Contains(output, "deadbeef") {
+			t.Errorf("summary contains secret: %q", output)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// Install the plugin by copying the DLL into the configuration directory before the container is started.
+const installCopy = "copy"
+
+// Install the plugin from a local plugin repository using the Packages API.
+const installRepository = "repository"
+
+// Name of the plugin archive served by the local plugin repository.
+const repositoryArchive = "intro-skipper.zip"
+
+// Plugin repository serving a manifest which only contains the plugin under test.
+type pluginRepository struct {
+	// Address the repository is reachable at from inside local containers.
+	URL string
+
+	// Plugin metadata, as listed in the manifest.
+	Guid    string
+	Name    string
+	Version string
+
+	manifest []byte
+	archive  []byte
+}
+
+// Local plugin repository, if any server installs the plugin from it.
+var repository *pluginRepository
+
+// Minimal representation of a manifest.json entry. Unknown fields are preserved.
+type manifestPlugin map[string]interface{}
+
+// Builds a plugin repository from the project's manifest and the plugin DLL. The manifest only contains a single
+// version of the plugin, using the provided version and the target ABI of the newest published version.
+func buildRepository(manifestPath, dll, version, baseURL string) (*pluginRepository, error) {
+	raw, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var plugins []manifestPlugin
+	if err := json.Unmarshal(raw, &plugins); err != nil {
+		return nil, fmt.Errorf("unable to parse manifest: %w", err)
+	} else if len(plugins) == 0 {
+		return nil, errors.New("manifest does not contain any plugins")
+	}
+
+	plugin := plugins[0]
+
+	versions, _ := plugin["versions"].([]interface{})
+	if len(versions) == 0 {
+		return nil, errors.New("manifest does not contain any versions")
+	}
+
+	newest, _ := versions[0].(map[string]interface{})
+	targetAbi, _ := newest["targetAbi"].(string)
+	if targetAbi == "" {
+		return nil, errors.New("newest version in the manifest does not have a target ABI")
+	}
+
+	// Package the DLL the same way as a release
+	archive, err := zipFile(dll)
+	if err != nil {
+		return nil, err
+	}
+
+	checksum := md5.Sum(archive)
+
+	plugin["versions"] = []interface{}{
+		map[string]interface{}{
+			"version":   version,
+			"changelog": "Local build under test",
+			"targetAbi": targetAbi,
+			"sourceUrl": baseURL + "/" + repositoryArchive,
+			"checksum":  hex.EncodeToString(checksum[:]),
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+		},
+	}
+
+	manifest, err := json.MarshalIndent([]manifestPlugin{plugin}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	guid, _ := plugin["guid"].(string)
+	name, _ := plugin["name"].(string)
+
+	return &pluginRepository{
+		URL:      baseURL + "/manifest.json",
+		Guid:     guid,
+		Name:     name,
+		Version:  version,
+		manifest: manifest,
+		archive:  archive,
+	}, nil
+}
+
+// Returns a zip archive containing only the provided file.
+func zipFile(source string) ([]byte, error) {
+	contents, err := os.ReadFile(source)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+
+	w, err := archive.Create(filepath.Base(source))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(contents); err != nil {
+		return nil, err
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Serves the manifest and plugin archive.
+func (r *pluginRepository) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.URL.Path {
+	case "/manifest.json":
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(r.manifest)
+
+	case "/" + repositoryArchive:
+		w.Header().Set("Content-Type", "application/zip")
+		w.Write(r.archive)
+
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// Builds the local plugin repository and starts serving it on all interfaces, so that it is reachable from local
+// containers at the container address.
+func startRepository(manifestPath, dll, version string) (*pluginRepository, error) {
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return nil, err
+	}
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	baseURL := fmt.Sprintf("http://%s:%d", containerAddress, port)
+
+	repo, err := buildRepository(manifestPath, dll, version, baseURL)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	go http.Serve(listener, repo)
+
+	return repo, nil
+}
+
+// Registers the local plugin repository on a server, installs the plugin from it and restarts the server.
+func stageInstall(run *serverRun) error {
+	if run.server.Install != installRepository {
+		return errSkipStage
+	}
+
+	makeUrl := func(u string) string {
+		return fmt.Sprintf("%s/%s", run.server.Address, u)
+	}
+
+	run.log.Infof("Adding plugin repository %s", repository.URL)
+	repositories := fmt.Sprintf(`[{"Name":"E2E","Url":"%s","Enabled":true}]`, repository.URL)
+	if _, err := apiRequest(run.log, "POST", makeUrl("Repositories?api_key="+run.apiKey), repositories); err != nil {
+		return fmt.Errorf("failed to add plugin repository: %w", err)
+	}
+
+	run.log.Infof("Installing %s %s", repository.Name, repository.Version)
+	query := url.Values{}
+	query.Set("assemblyGuid", repository.Guid)
+	query.Set("version", repository.Version)
+	query.Set("repositoryUrl", repository.URL)
+	query.Set("api_key", run.apiKey)
+
+	install := makeUrl("Packages/Installed/" + url.PathEscape(repository.Name) + "?" + query.Encode())
+	if _, err := apiRequest(run.log, "POST", install, ""); err != nil {
+		return fmt.Errorf("failed to install plugin: %w", err)
+	}
+
+	// Installation happens in the background. Failures (such as checksum mismatches or an incompatible target ABI)
+	// are only written to the server log, so wait for the plugin to appear on disk.
+	pluginsDirectory := path.Dir(pluginDirectory(run.server, run.configurationDirectory))
+	if err := waitForInstall(pluginsDirectory, repository.Name, 30*time.Second); err != nil {
+		return err
+	}
+
+	// Load the plugin
+	if err := run.runtime.Restart(run.server.ContainerName); err != nil {
+		return fmt.Errorf("failed to restart container: %w", err)
+	}
+
+	time.Sleep(time.Second)
+	return waitForServerReady(run)
+}
+
+// Waits for a plugin to be extracted into the plugins directory. Jellyfin installs plugins into a directory named
+// after the plugin's name and version.
+func waitForInstall(pluginsDirectory, name string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		matches, err := filepath.Glob(filepath.Join(pluginsDirectory, name+"_*", "*.dll"))
+		if err != nil {
+			return err
+		} else if len(matches) > 0 {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf(
+				"plugin was not installed after %s, check the server log for checksum or target ABI errors",
+				timeout)
+		}
+
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// Returns true if any server installs the plugin from the local plugin repository.
+func usesRepository(servers []Server) bool {
+	for _, server := range servers {
+		if !server.Skip && server.Install == installRepository {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildRepository(t *testing.T) {
+	dll := path.Join(t.TempDir(), "ConfusedPolarBear.Plugin.IntroSkipper.dll")
+	if err := os.WriteFile(dll, []byte("MZ"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := buildRepository("../../../manifest.json", dll, "0.1.8.0", "http://10.0.0.1:1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if repo.Guid != "c83d86bb-a1e0-4c35-a113-e2101cf4ee6b" || repo.Name != "Intro Skipper" {
+		t.Errorf("incorrect plugin metadata: %+v", repo)
+	}
+
+	server := httptest.NewServer(repo)
+	defer server.Close()
+
+	get := func(name string) []byte {
+		res, err := server.Client().Get(server.URL + "/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return body
+	}
+
+	var manifest []struct {
+		Guid     string
+		Versions []struct {
+			Version   string
+			TargetAbi string
+			SourceUrl string
+			Checksum  string
+		}
+	}
+
+	if err := json.Unmarshal(get("manifest.json"), &manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(manifest) != 1 || len(manifest[0].Versions) != 1 {
+		t.Fatalf("manifest should contain exactly one version: %+v", manifest)
+	}
+
+	version := manifest[0].Versions[0]
+	if version.Version != "0.1.8.0" || version.TargetAbi == "" || version.SourceUrl != "http://10.0.0.1:1234/intro-skipper.zip" {
+		t.Errorf("incorrect version: %+v", version)
+	}
+
+	// The checksum must match the served archive, which must contain the DLL
+	archive := get("intro-skipper.zip")
+	checksum := md5.Sum(archive)
+	if version.Checksum != hex.EncodeToString(checksum[:]) {
+		t.Error("checksum does not match the archive")
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reader.File) != 1 || reader.File[0].Name != "ConfusedPolarBear.Plugin.IntroSkipper.dll" {
+		t.Errorf("archive has incorrect contents: %v", reader.File)
+	}
+}
+
+func TestWaitForInstall(t *testing.T) {
+	plugins := t.TempDir()
+
+	if err := waitForInstall(plugins, "Intro Skipper", time.Millisecond); err == nil {
+		t.Error("missing plugin should time out")
+	}
+
+	installed := path.Join(plugins, "Intro Skipper_0.1.8.0")
+	if err := os.Mkdir(installed, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path.Join(installed, "plugin.dll"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := waitForInstall(plugins, "Intro Skipper", time.Millisecond); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRepositoryInstallValidation(t *testing.T) {
+	raw := `{"servers": [{"address": "http://example.com", "install": "repository"}, {"image": "a", "install": "zip"}]}`
+
+	_, err := parseConfiguration([]byte(raw), nil)
+	if err == nil {
+		t.Fatal("invalid install methods were accepted")
+	}
+
+	expected := "servers[0].install: only local containers can install the plugin from a repository"
+	if !strings.Contains(err.Error(), expected) {
+		t.Errorf("error does not contain %q:\n%s", expected, err)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Interval between samples of a container's resource usage.
+var resourceSampleInterval = 5 * time.Second
+
+// Resource usage of a container at a single point during analysis. Matches ResourceSample in the verifier.
+type ResourceSample struct {
+	// Time since sampling started.
+	Elapsed time.Duration
+
+	// CPU usage as a percentage of a single core.
+	CPUPercent float64
+
+	// Memory used by the container.
+	MemoryBytes uint64
+
+	// Progress of the running analysis task, as a percentage.
+	Progress float64
+}
+
+// Resource usage of a container sampled during analysis. Matches ResourceUsage in the verifier.
+type ResourceUsage struct {
+	Samples []ResourceSample
+
+	PeakCPUPercent    float64
+	AverageCPUPercent float64
+
+	PeakMemoryBytes    uint64
+	AverageMemoryBytes uint64
+}
+
+// Returns a short summary of the peak and average usage.
+func (u ResourceUsage) String() string {
+	return fmt.Sprintf("peak %0.1f%% CPU, %s; average %0.1f%% CPU, %s",
+		u.PeakCPUPercent,
+		formatBytes(u.PeakMemoryBytes),
+		u.AverageCPUPercent,
+		formatBytes(u.AverageMemoryBytes))
+}
+
+// Formats a number of bytes in mebibytes.
+func formatBytes(bytes uint64) string {
+	return fmt.Sprintf("%0.1f MiB", float64(bytes)/(1024*1024))
+}
+
+// Calculates the peak and average usage of all samples.
+func summarizeResources(samples []ResourceSample) ResourceUsage {
+	usage := ResourceUsage{Samples: samples}
+	if len(samples) == 0 {
+		return usage
+	}
+
+	var cpu, memory float64
+	for _, sample := range samples {
+		cpu += sample.CPUPercent
+		memory += float64(sample.MemoryBytes)
+
+		if sample.CPUPercent > usage.PeakCPUPercent {
+			usage.PeakCPUPercent = sample.CPUPercent
+		}
+
+		if sample.MemoryBytes > usage.PeakMemoryBytes {
+			usage.PeakMemoryBytes = sample.MemoryBytes
+		}
+	}
+
+	usage.AverageCPUPercent = cpu / float64(len(samples))
+	usage.AverageMemoryBytes = uint64(memory / float64(len(samples)))
+
+	return usage
+}
+
+// Samples resource usage and task progress every interval until stop is closed. Progress is carried over from the
+// previous sample while no task is running. The first error encountered is returned along with all samples which
+// were taken successfully.
+func sampleResources(
+	stats func() (ContainerStats, error),
+	progress func() (float64, bool, error),
+	interval time.Duration,
+	stop <-chan struct{}) (ResourceUsage, error) {
+
+	var samples []ResourceSample
+	var firstErr error
+	var lastProgress float64
+
+	start := time.Now()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		current, err := stats()
+		if err == nil {
+			if percent, running, progressErr := progress(); progressErr != nil {
+				err = progressErr
+			} else if running {
+				lastProgress = percent
+			}
+		}
+
+		if err == nil {
+			samples = append(samples, ResourceSample{
+				Elapsed:     time.Since(start),
+				CPUPercent:  current.CPUPercent,
+				MemoryBytes: current.MemoryBytes,
+				Progress:    lastProgress,
+			})
+		} else if firstErr == nil {
+			firstErr = err
+		}
+
+		select {
+		case <-stop:
+			return summarizeResources(samples), firstErr
+
+		case <-ticker.C:
+		}
+	}
+}
+
+// Returns the progress of the plugin's running scheduled task, if any.
+func analysisProgress(address, apiKey string) (float64, bool, error) {
+	tasks, err := fetchPluginTasks(discardLog, address, apiKey)
+	if err != nil {
+		return 0, false, err
+	}
+
+	for _, task := range tasks {
+		if task.CurrentProgressPercentage != nil {
+			return *task.CurrentProgressPercentage, true, nil
+		}
+	}
+
+	return 0, false, nil
+}
+
+// Starts sampling the resource usage of a local server's container in the background. The returned function stops
+// sampling and returns the results.
+func startResourceSampling(run *serverRun) func() (ResourceUsage, error) {
+	// Sampling commands are not logged as they would drown out the verifier's output
+	runtime, err := newContainerRuntime(run.common.Runtime, discardLog)
+	if err != nil {
+		return func() (ResourceUsage, error) { return ResourceUsage{}, err }
+	}
+
+	stats := func() (ContainerStats, error) {
+		return runtime.Stats(run.server.ContainerName)
+	}
+
+	progress := func() (float64, bool, error) {
+		return analysisProgress(run.server.Address, run.apiKey)
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	var usage ResourceUsage
+	go func() {
+		defer close(done)
+		usage, err = sampleResources(stats, progress, resourceSampleInterval, stop)
+	}()
+
+	return func() (ResourceUsage, error) {
+		close(stop)
+		<-done
+		return usage, err
+	}
+}
+
+// Adds resource usage to a previously saved report. All other fields in the report are preserved as is.
+func addResourcesToReport(path string, usage ResourceUsage) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var report map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &report); err != nil {
+		return fmt.Errorf("unable to parse report: %w", err)
+	}
+
+	if report["Resources"], err = json.Marshal(usage); err != nil {
+		return err
+	}
+
+	if raw, err = json.Marshal(report); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, raw, 0600)
+}
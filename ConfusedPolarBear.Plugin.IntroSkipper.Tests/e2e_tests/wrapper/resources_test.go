@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSummarizeResources(t *testing.T) {
+	usage := summarizeResources([]ResourceSample{
+		{CPUPercent: 50, MemoryBytes: 100 << 20},
+		{CPUPercent: 150, MemoryBytes: 300 << 20},
+	})
+
+	if usage.PeakCPUPercent != 150 || usage.AverageCPUPercent != 100 {
+		t.Errorf("incorrect CPU usage: %+v", usage)
+	}
+
+	if usage.PeakMemoryBytes != 300<<20 || usage.AverageMemoryBytes != 200<<20 {
+		t.Errorf("incorrect memory usage: %+v", usage)
+	}
+
+	if actual := usage.String(); actual != "peak 150.0% CPU, 300.0 MiB; average 100.0% CPU, 200.0 MiB" {
+		t.Errorf("incorrect summary: %s", actual)
+	}
+}
+
+func TestSampleResources(t *testing.T) {
+	runtime := newFakeRuntime()
+	runtime.Usage["jf-e2e"] = ContainerStats{CPUPercent: 80, MemoryBytes: 1 << 30}
+
+	stats := func() (ContainerStats, error) {
+		return runtime.Stats("jf-e2e")
+	}
+
+	// The task runs for two samples, fails to report progress once and is then finished
+	stop := make(chan struct{})
+	var once sync.Once
+	calls := 0
+
+	progress := func() (float64, bool, error) {
+		calls++
+		switch calls {
+		case 1:
+			return 25, true, nil
+		case 2:
+			return 50, true, nil
+		case 3:
+			return 0, false, errors.New("server is busy")
+		default:
+			once.Do(func() { close(stop) })
+			return 0, false, nil
+		}
+	}
+
+	usage, err := sampleResources(stats, progress, 10*time.Millisecond, stop)
+	if err == nil || err.Error() != "server is busy" {
+		t.Errorf("expected the first error to be returned, got %v", err)
+	}
+
+	if len(usage.Samples) < 3 {
+		t.Fatalf("expected at least three samples, found %d", len(usage.Samples))
+	}
+
+	// Progress is carried over once the task is no longer running
+	if usage.Samples[0].Progress != 25 || usage.Samples[1].Progress != 50 || usage.Samples[2].Progress != 50 {
+		t.Errorf("incorrect progress: %+v", usage.Samples)
+	}
+
+	if usage.PeakMemoryBytes != 1<<30 || usage.AverageCPUPercent != 80 {
+		t.Errorf("incorrect usage: %+v", usage)
+	}
+}
+
+func TestAddResourcesToReport(t *testing.T) {
+	report := filepath.Join(t.TempDir(), "report.json")
+	if err := os.WriteFile(report, []byte(`{"Runtime":60000000000,"Intros":[{"EpisodeId":"1"}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	usage := summarizeResources([]ResourceSample{{Elapsed: time.Second, CPUPercent: 10, MemoryBytes: 1024, Progress: 5}})
+	if err := addResourcesToReport(report, usage); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var saved struct {
+		Runtime   time.Duration
+		Intros    []map[string]string
+		Resources ResourceUsage
+	}
+
+	if err := json.Unmarshal(raw, &saved); err != nil {
+		t.Fatal(err)
+	}
+
+	if saved.Runtime != time.Minute || len(saved.Intros) != 1 {
+		t.Errorf("existing fields were not preserved: %s", raw)
+	}
+
+	if saved.Resources.PeakMemoryBytes != 1024 || len(saved.Resources.Samples) != 1 {
+		t.Errorf("resource usage was not saved: %s", raw)
+	}
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Port published from a container to the host.
+type PortMapping struct {
+	Host      int
+	Container int
+}
+
+// Host directory mounted into a container.
+type VolumeMount struct {
+	Source   string
+	Target   string
+	ReadOnly bool
+}
+
+// Settings used to start a container.
+type ContainerSpec struct {
+	Name    string
+	Image   string
+	Labels  map[string]string
+	Ports   []PortMapping
+	Volumes []VolumeMount
+}
+
+// Subset of the state of a container returned by inspect.
+type ContainerInfo struct {
+	Name    string
+	Image   string
+	Running bool
+	Status  string
+}
+
+// Current resource usage of a container.
+type ContainerStats struct {
+	// CPU usage as a percentage of a single core.
+	CPUPercent float64
+
+	// Memory used by the container.
+	MemoryBytes uint64
+}
+
+// A container runtime (such as Docker or Podman) used to manage Jellyfin and Selenium containers.
+type ContainerRuntime interface {
+	// Name of the runtime.
+	Name() string
+
+	// Start a container in the background. The container is removed when it is stopped.
+	Run(spec ContainerSpec) error
+
+	// Stop a running container.
+	Stop(name string) error
+
+	// Restart a running container.
+	Restart(name string) error
+
+	// Get all output logged by a container.
+	Logs(name string) (string, error)
+
+	// Get the current state of a container.
+	Inspect(name string) (ContainerInfo, error)
+
+	// Get the current resource usage of a running container.
+	Stats(name string) (ContainerStats, error)
+
+	// Change the owner of a host directory (recursively) to a user inside of containers.
+	Chown(owner, path string) error
+
+	// Bring up the Selenium services in the compose file.
+	ComposeUp() error
+
+	// Bring down the Selenium services in the compose file.
+	ComposeDown() error
+
+	// List the names of all containers, running or stopped, which match a filter such as "label=key" or "name=text".
+	List(filter string) ([]string, error)
+
+	// Forcibly remove a container, stopping it first if it is running.
+	Remove(name string) error
+
+	// Returns a copy of the runtime whose commands are not killed when the wrapper is interrupted. Used to clean up.
+	Detached() ContainerRuntime
+}
+
+// Returns the container runtime with the provided name which logs all commands to log. Defaults to Docker.
+func newContainerRuntime(name string, log *Logger) (ContainerRuntime, error) {
+	return newCLIRuntime(name, log)
+}
+
+// Returns the command line container runtime with the provided name. Defaults to Docker.
+func newCLIRuntime(name string, log *Logger) (*cliRuntime, error) {
+	switch name {
+	case "", "docker":
+		return &cliRuntime{log: log, binary: "docker", compose: []string{"docker-compose"}}, nil
+
+	case "podman":
+		return &cliRuntime{
+			log:     log,
+			binary:  "podman",
+			compose: []string{"podman-compose"},
+			// Rootless Podman maps container users into a user namespace which must be entered to chown
+			chown: []string{"podman", "unshare", "chown"},
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown container runtime %q", name)
+	}
+}
+
+// Container runtime which is managed through a Docker compatible command line interface.
+type cliRuntime struct {
+	log *Logger
+
+	// Kills running commands once canceled. Defaults to the run context.
+	ctx context.Context
+
+	// Container runtime executable.
+	binary string
+
+	// Command (and any leading arguments) used to manage compose files.
+	compose []string
+
+	// Command (and any leading arguments) used to change file ownership. Defaults to chown.
+	chown []string
+}
+
+func (r *cliRuntime) Name() string {
+	return r.binary
+}
+
+// Returns the options used to run a command with a timeout.
+func (r *cliRuntime) options(timeout time.Duration) ProgramOptions {
+	return ProgramOptions{Log: r.log, Context: r.ctx, Timeout: timeout}
+}
+
+func (r *cliRuntime) Run(spec ContainerSpec) error {
+	_, err := r.runCommand(spec).Capture(r.options(60 * time.Second))
+	return err
+}
+
+func (r *cliRuntime) Stop(name string) error {
+	_, err := r.stopCommand(name).Capture(r.options(15 * time.Second))
+	return err
+}
+
+func (r *cliRuntime) Restart(name string) error {
+	_, err := r.restartCommand(name).Capture(r.options(15 * time.Second))
+	return err
+}
+
+func (r *cliRuntime) Logs(name string) (string, error) {
+	// Containers log to both standard output and standard error
+	opts := r.options(15 * time.Second)
+	opts.Quiet = true
+
+	result := r.logsCommand(name).Run(opts)
+	return result.Output, result.Err
+}
+
+func (r *cliRuntime) Inspect(name string) (ContainerInfo, error) {
+	raw, err := r.command("inspect", name).Capture(r.options(15 * time.Second))
+	if err != nil {
+		return ContainerInfo{}, err
+	}
+
+	return parseInspect(raw)
+}
+
+func (r *cliRuntime) Stats(name string) (ContainerStats, error) {
+	raw, err := r.statsCommand(name).Capture(r.options(15 * time.Second))
+	if err != nil {
+		return ContainerStats{}, err
+	}
+
+	return parseStats(raw)
+}
+
+func (r *cliRuntime) Chown(owner, path string) error {
+	_, err := r.chownCommand(owner, path).Capture(r.options(10 * time.Second))
+	return err
+}
+
+func (r *cliRuntime) ComposeUp() error {
+	_, err := r.composeCommand("up", "-d").Capture(r.options(60 * time.Second))
+	return err
+}
+
+func (r *cliRuntime) ComposeDown() error {
+	_, err := r.composeCommand("down").Capture(r.options(60 * time.Second))
+	return err
+}
+
+func (r *cliRuntime) List(filter string) ([]string, error) {
+	raw, err := r.command("ps", "--all", "--filter", filter, "--format", "{{.Names}}").Capture(r.options(15 * time.Second))
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Fields(raw), nil
+}
+
+func (r *cliRuntime) Remove(name string) error {
+	_, err := r.command("rm", "--force", name).Capture(r.options(30 * time.Second))
+	return err
+}
+
+func (r *cliRuntime) Detached() ContainerRuntime {
+	detached := *r
+	detached.ctx = context.Background()
+	return &detached
+}
+
+// Returns a command which runs the runtime executable with the provided arguments.
+func (r *cliRuntime) command(args ...string) Command {
+	return Command{Program: r.binary, Args: args}
+}
+
+func (r *cliRuntime) runCommand(spec ContainerSpec) Command {
+	return r.command(runArguments(spec)...)
+}
+
+func (r *cliRuntime) stopCommand(name string) Command {
+	return r.command("stop", name)
+}
+
+func (r *cliRuntime) restartCommand(name string) Command {
+	return r.command("restart", name)
+}
+
+func (r *cliRuntime) logsCommand(name string) Command {
+	return r.command("logs", name)
+}
+
+func (r *cliRuntime) statsCommand(name string) Command {
+	// Both Docker and Podman support these template fields
+	return r.command("stats", "--no-stream", "--format", "{{.CPUPerc}}\t{{.MemUsage}}", name)
+}
+
+func (r *cliRuntime) chownCommand(owner, path string) Command {
+	command := r.chown
+	if len(command) == 0 {
+		command = []string{"chown"}
+	}
+
+	args := append(append([]string{}, command[1:]...), "-R", owner, path)
+	return Command{Program: command[0], Args: args}
+}
+
+func (r *cliRuntime) composeCommand(args ...string) Command {
+	return Command{Program: r.compose[0], Args: append(append([]string{}, r.compose[1:]...), args...)}
+}
+
+// Returns the arguments used to start a detached container which is removed once stopped.
+func runArguments(spec ContainerSpec) []string {
+	args := []string{"run", "--detach", "--rm", "--name", spec.Name}
+
+	// Labels are sorted so that the command line is stable
+	var labels []string
+	for key, value := range spec.Labels {
+		labels = append(labels, key+"="+value)
+	}
+
+	sort.Strings(labels)
+	for _, label := range labels {
+		args = append(args, "--label", label)
+	}
+
+	for _, port := range spec.Ports {
+		args = append(args, "-p", fmt.Sprintf("%d:%d", port.Host, port.Container))
+	}
+
+	for _, volume := range spec.Volumes {
+		mode := "rw"
+		if volume.ReadOnly {
+			mode = "ro"
+		}
+
+		args = append(args, "-v", fmt.Sprintf("%s:%s:%s", volume.Source, volume.Target, mode))
+	}
+
+	return append(args, spec.Image)
+}
+
+// Parses the output of "docker inspect" or "podman inspect" for a single container.
+func parseInspect(raw string) (ContainerInfo, error) {
+	var containers []struct {
+		Name  string
+		State struct {
+			Running bool
+			Status  string
+		}
+		Config struct {
+			Image string
+		}
+	}
+
+	if err := json.Unmarshal([]byte(raw), &containers); err != nil {
+		return ContainerInfo{}, err
+	} else if len(containers) != 1 {
+		return ContainerInfo{}, errors.New("expected exactly one container to be inspected")
+	}
+
+	c := containers[0]
+	return ContainerInfo{
+		Name:    strings.TrimPrefix(c.Name, "/"),
+		Image:   c.Config.Image,
+		Running: c.State.Running,
+		Status:  c.State.Status,
+	}, nil
+}
+
+// Matches a size with an optional unit, i.e. "812.3MiB" or "1.2 GB".
+var sizeRegex = regexp.MustCompile(`^([0-9.]+)\s*([A-Za-z]*)$`)
+
+// Multiplier of every size unit used by "docker stats" (binary units) and "podman stats" (decimal units).
+var sizeUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"kb":  1e3,
+	"kib": 1 << 10,
+	"mb":  1e6,
+	"mib": 1 << 20,
+	"gb":  1e9,
+	"gib": 1 << 30,
+	"tb":  1e12,
+	"tib": 1 << 40,
+}
+
+// Parses the output of "docker stats" or "podman stats" formatted as "CPU%\tUSAGE / LIMIT".
+func parseStats(raw string) (ContainerStats, error) {
+	fields := strings.Split(strings.TrimSpace(raw), "\t")
+	if len(fields) != 2 {
+		return ContainerStats{}, fmt.Errorf("unexpected stats output %q", raw)
+	}
+
+	cpu, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(fields[0], "%")), 64)
+	if err != nil {
+		return ContainerStats{}, fmt.Errorf("unable to parse CPU usage %q", fields[0])
+	}
+
+	usage := strings.TrimSpace(strings.SplitN(fields[1], "/", 2)[0])
+	memory, err := parseSize(usage)
+	if err != nil {
+		return ContainerStats{}, err
+	}
+
+	return ContainerStats{CPUPercent: cpu, MemoryBytes: memory}, nil
+}
+
+// Parses a human readable size into bytes.
+func parseSize(raw string) (uint64, error) {
+	match := sizeRegex.FindStringSubmatch(raw)
+	if match == nil {
+		return 0, fmt.Errorf("unable to parse size %q", raw)
+	}
+
+	unit, ok := sizeUnits[strings.ToLower(match[2])]
+	if !ok {
+		return 0, fmt.Errorf("unknown size unit %q", match[2])
+	}
+
+	value, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("unable to parse size %q", raw)
+	}
+
+	return uint64(value * unit), nil
+}
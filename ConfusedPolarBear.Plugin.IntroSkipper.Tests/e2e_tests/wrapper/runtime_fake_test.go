@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Container runtime which records all operations instead of running any containers.
+type fakeRuntime struct {
+	// All operations performed, i.e. "run jf-e2e" or "chown 911:911 /path".
+	Calls []string
+
+	// Specs of all started containers.
+	Started []ContainerSpec
+
+	// Logs returned for each container.
+	ContainerLogs map[string]string
+
+	// Resource usage returned for each container.
+	Usage map[string]ContainerStats
+
+	// Operation names (i.e. "restart") which should fail.
+	Failures map[string]bool
+
+	running map[string]ContainerSpec
+}
+
+func newFakeRuntime() *fakeRuntime {
+	return &fakeRuntime{
+		ContainerLogs: make(map[string]string),
+		Usage:         make(map[string]ContainerStats),
+		Failures:      make(map[string]bool),
+		running:       make(map[string]ContainerSpec),
+	}
+}
+
+// Records an operation and returns an error if it was configured to fail.
+func (r *fakeRuntime) record(operation, argument string) error {
+	r.Calls = append(r.Calls, operation+" "+argument)
+
+	if r.Failures[operation] {
+		return fmt.Errorf("%s %s failed", operation, argument)
+	}
+
+	return nil
+}
+
+func (r *fakeRuntime) Name() string {
+	return "fake"
+}
+
+func (r *fakeRuntime) Run(spec ContainerSpec) error {
+	if err := r.record("run", spec.Name); err != nil {
+		return err
+	}
+
+	r.Started = append(r.Started, spec)
+	r.running[spec.Name] = spec
+	return nil
+}
+
+func (r *fakeRuntime) Stop(name string) error {
+	if err := r.record("stop", name); err != nil {
+		return err
+	}
+
+	if _, ok := r.running[name]; !ok {
+		return errors.New("container is not running")
+	}
+
+	delete(r.running, name)
+	return nil
+}
+
+func (r *fakeRuntime) Restart(name string) error {
+	if err := r.record("restart", name); err != nil {
+		return err
+	}
+
+	if _, ok := r.running[name]; !ok {
+		return errors.New("container is not running")
+	}
+
+	return nil
+}
+
+func (r *fakeRuntime) Logs(name string) (string, error) {
+	return r.ContainerLogs[name], r.record("logs", name)
+}
+
+func (r *fakeRuntime) Inspect(name string) (ContainerInfo, error) {
+	if err := r.record("inspect", name); err != nil {
+		return ContainerInfo{}, err
+	}
+
+	spec, running := r.running[name]
+	return ContainerInfo{Name: name, Image: spec.Image, Running: running}, nil
+}
+
+func (r *fakeRuntime) Stats(name string) (ContainerStats, error) {
+	return r.Usage[name], r.record("stats", name)
+}
+
+func (r *fakeRuntime) Chown(owner, path string) error {
+	return r.record("chown", owner+" "+path)
+}
+
+func (r *fakeRuntime) ComposeUp() error {
+	return r.record("compose", "up")
+}
+
+func (r *fakeRuntime) ComposeDown() error {
+	return r.record("compose", "down")
+}
+
+func (r *fakeRuntime) List(filter string) ([]string, error) {
+	if err := r.record("list", filter); err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for name, spec := range r.running {
+		if label := strings.TrimPrefix(filter, "label="); label != filter {
+			if _, ok := spec.Labels[label]; ok {
+				names = append(names, name)
+			}
+		} else if strings.Contains(name, strings.TrimPrefix(filter, "name=")) {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+func (r *fakeRuntime) Remove(name string) error {
+	if err := r.record("remove", name); err != nil {
+		return err
+	}
+
+	delete(r.running, name)
+	return nil
+}
+
+func (r *fakeRuntime) Detached() ContainerRuntime {
+	return r
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRunArguments(t *testing.T) {
+	spec := ContainerSpec{
+		Name:   "jf-e2e",
+		Image:  "jellyfin/jellyfin",
+		Labels: map[string]string{"b": "2", "a": "1"},
+		Ports:  []PortMapping{{Host: 8097, Container: 8096}},
+		Volumes: []VolumeMount{
+			{Source: "/dev/shm/config", Target: "/config"},
+			{Source: "/srv/TV", Target: "/media", ReadOnly: true},
+		},
+	}
+
+	expected := []string{"run", "--detach", "--rm", "--name", "jf-e2e", "--label", "a=1", "--label", "b=2", "-p", "8097:8096",
+		"-v", "/dev/shm/config:/config:rw", "-v", "/srv/TV:/media:ro", "jellyfin/jellyfin"}
+
+	if actual := runArguments(spec); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("incorrect run arguments: %v", actual)
+	}
+}
+
+func TestParseInspect(t *testing.T) {
+	raw := `[{"Name":"/jf-e2e","State":{"Running":true,"Status":"running"},"Config":{"Image":"jellyfin/jellyfin"}}]`
+
+	info, err := parseInspect(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := ContainerInfo{Name: "jf-e2e", Image: "jellyfin/jellyfin", Running: true, Status: "running"}
+	if info != expected {
+		t.Errorf("incorrect container info: %+v", info)
+	}
+
+	if _, err := parseInspect("[]"); err == nil {
+		t.Error("expected an error when no containers are inspected")
+	}
+}
+
+func TestParseStats(t *testing.T) {
+	tests := map[string]ContainerStats{
+		"12.50%\t812.5MiB / 7.6GiB\n": {CPUPercent: 12.5, MemoryBytes: 851968000},   // Docker
+		"205.10%\t1.2GB / 8.2GB\n":    {CPUPercent: 205.1, MemoryBytes: 1200000000}, // Podman
+		"0.00%\t0B / 0B":              {},
+	}
+
+	for raw, expected := range tests {
+		stats, err := parseStats(raw)
+		if err != nil {
+			t.Errorf("unable to parse %q: %s", raw, err)
+		} else if stats != expected {
+			t.Errorf("incorrect stats for %q: %+v", raw, stats)
+		}
+	}
+
+	for _, raw := range []string{"", "--\t--", "1%\t12 parsecs / 1GB"} {
+		if _, err := parseStats(raw); err == nil {
+			t.Errorf("invalid stats %q were parsed", raw)
+		}
+	}
+}
+
+func TestNewContainerRuntime(t *testing.T) {
+	for _, name := range []string{"", "docker", "podman"} {
+		if _, err := newContainerRuntime(name, rootLog); err != nil {
+			t.Errorf("runtime %q: %s", name, err)
+		}
+	}
+
+	if _, err := newContainerRuntime("lxc", rootLog); err == nil {
+		t.Error("expected an error for an unknown runtime")
+	}
+}
+
+// Library mounted in all container tests.
+var testLibraries = resolveLibraries(Common{Library: "/srv/TV"})
+
+// Sets up a fake plugin DLL and configuration root for container tests.
+func setupContainerTest(t *testing.T) {
+	root := t.TempDir()
+
+	oldRoot, oldPlugin := configurationRoot, pluginPath
+	t.Cleanup(func() {
+		configurationRoot, pluginPath = oldRoot, oldPlugin
+	})
+
+	configurationRoot = root
+	pluginPath = path.Join(root, "plugin.dll")
+	if err := os.WriteFile(pluginPath, []byte("MZ"), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStartContainer(t *testing.T) {
+	setupContainerTest(t)
+	runtime := newFakeRuntime()
+
+	server := Server{Image: "jellyfin/jellyfin:10.8.9", ContainerName: "jf-e2e-1", Port: 8100}
+	directory, err := startContainer(rootLog, runtime, server, testLibraries, pluginPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The plugin must be installed before the container is started
+	if _, err := os.Stat(path.Join(directory, "plugins", "intro-skipper", "plugin.dll")); err != nil {
+		t.Errorf("plugin was not installed: %s", err)
+	}
+
+	if !reflect.DeepEqual(runtime.Calls, []string{"run jf-e2e-1"}) {
+		t.Errorf("unexpected runtime calls: %v", runtime.Calls)
+	}
+
+	spec := runtime.Started[0]
+	if spec.Image != server.Image || spec.Ports[0].Host != 8100 || spec.Volumes[0].Source != directory || !spec.Volumes[1].ReadOnly {
+		t.Errorf("container was started with an incorrect spec: %+v", spec)
+	}
+
+	if err := stopContainer(rootLog, runtime, "jf-e2e-1", directory); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := os.Stat(directory); !os.IsNotExist(err) {
+		t.Error("configuration directory was not deleted")
+	}
+}
+
+func TestStartLSIOContainer(t *testing.T) {
+	setupContainerTest(t)
+	runtime := newFakeRuntime()
+
+	server := Server{Image: "lscr.io/linuxserver/jellyfin", ContainerName: "jf-e2e-2"}
+	directory, err := startContainer(rootLog, runtime, server, testLibraries, pluginPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(path.Join(directory, "data", "plugins", "intro-skipper", "plugin.dll")); err != nil {
+		t.Errorf("plugin was not installed: %s", err)
+	}
+
+	// Plugin directory ownership must be changed before the container is started
+	if len(runtime.Calls) != 2 || !strings.HasPrefix(runtime.Calls[0], "chown 911:911 ") || runtime.Calls[1] != "run jf-e2e-2" {
+		t.Errorf("unexpected runtime calls: %v", runtime.Calls)
+	}
+}
+
+func TestStopContainerAfterFailure(t *testing.T) {
+	setupContainerTest(t)
+	runtime := newFakeRuntime()
+	runtime.Failures["run"] = true
+
+	server := Server{Image: "jellyfin/jellyfin", ContainerName: "jf-e2e-3"}
+	directory, err := startContainer(rootLog, runtime, server, testLibraries, pluginPath)
+	if err == nil {
+		t.Fatal("expected container start to fail")
+	}
+
+	// The configuration directory must still be removed even though the container never started
+	if err := stopContainer(rootLog, runtime, server.ContainerName, directory); err == nil {
+		t.Error("expected stopping a container that never started to fail")
+	}
+
+	if _, err := os.Stat(directory); !os.IsNotExist(err) {
+		t.Error("configuration directory was not deleted")
+	}
+}
@@ -1,26 +1,160 @@
 package main
 
+import "time"
+
 type Configuration struct {
+	// Optional path to the JSON schema, used by editors for completion and validation.
+	Schema string `json:"$schema"`
+
 	Common  Common   `json:"common"`
 	Servers []Server `json:"servers"`
+
+	// Templates which are expanded into additional servers.
+	Matrix []Matrix `json:"matrix"`
 }
 
 type Common struct {
+	// Host directory mounted at /media in local containers. A TV library is created from its TV folder. Ignored if
+	// Libraries is set.
 	Library string `json:"library"`
 	Episode string `json:"episode"`
+
+	// Libraries to create on local servers.
+	Libraries []Library `json:"libraries"`
+
+	// Container runtime used for local servers and Selenium. Either "docker" (the default) or "podman".
+	Runtime string `json:"runtime"`
+
+	// Maximum number of servers to test at the same time. Defaults to 1.
+	MaxParallelism int `json:"max_parallelism"`
+
+	// How long and how often to check whether local servers have finished starting.
+	Readiness Readiness `json:"readiness"`
+}
+
+// Settings for checking whether a server has finished starting and loaded the plugin.
+type Readiness struct {
+	// Maximum time to wait for a server to become ready, i.e. "2m". Defaults to 1m.
+	Timeout string `json:"timeout"`
+
+	// Time to wait before the first check. Defaults to 1s.
+	Interval string `json:"interval"`
+
+	// Factor the interval is multiplied by after every failed check. Defaults to 1.5.
+	Backoff float64 `json:"backoff"`
+
+	// Longest time to wait between checks. Defaults to 10s.
+	MaxInterval string `json:"max_interval"`
 }
 
 type Server struct {
-	Skip        bool     `json:"skip"`
-	Comment     string   `json:"comment"`
-	Address     string   `json:"address"`
-	Image       string   `json:"image"`
-	Username    string   `json:"username"`
-	Password    string   `json:"password"`
-	Browsers    []string `json:"browsers"`
-	Tests       []string `json:"tests"`
-	ManualTests bool     `json:"manual_tests"`
+	Skip     bool     `json:"skip"`
+	Comment  string   `json:"comment"`
+	Address  string   `json:"address"`
+	Image    string   `json:"image"`
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	Browsers []string `json:"browsers"`
+	Tests    []string `json:"tests"`
+
+	// Manual tests which the tester is prompted to perform before the Selenium tests are run.
+	Checkpoints []Checkpoint `json:"checkpoints"`
+
+	// Report to compare the analysis results against. Either a path to a report or "latest" to use the most
+	// recent previous report for this server's comment.
+	Baseline string `json:"baseline"`
+
+	// Limits on how much the analysis results may regress compared to the baseline.
+	Thresholds Thresholds `json:"thresholds"`
+
+	// Set to "upgrade" to analyze episodes with the plugin DLL at UpgradeFrom before upgrading to the plugin
+	// under test.
+	Scenario    string `json:"scenario"`
+	UpgradeFrom string `json:"upgrade_from"`
+
+	// How the plugin is installed on local containers. Either "copy" (the default) to copy the DLL into the
+	// configuration directory, or "repository" to install it from a local plugin repository.
+	Install string `json:"install"`
+
+	// Plugin settings to change before analysis, i.e. {"AnalyzeSeasonZero": true}. All other settings are left as is.
+	PluginConfiguration map[string]interface{} `json:"plugin_configuration"`
 
 	// These properties are set at runtime
-	Docker bool `json:"-"`
+	Docker        bool   `json:"-"`
+	ContainerName string `json:"-"`
+	Port          int    `json:"-"`
+
+	// Name of the matrix and image this server was expanded from, if any.
+	Matrix      string `json:"-"`
+	MatrixImage string `json:"-"`
+}
+
+// Library created on local servers. All paths are mounted read only.
+type Library struct {
+	Name string `json:"name"`
+
+	// Either "tvshows", "movies" or "mixed".
+	CollectionType string `json:"collection_type"`
+
+	// Host directories containing the library's media.
+	Paths []string `json:"paths"`
+
+	// Volumes mounted for this library and the paths they are mounted at in the container. Set at runtime.
+	Mounts         []VolumeMount `json:"-"`
+	ContainerPaths []string      `json:"-"`
+}
+
+// Template server which is tested against every combination of image, tag and browser.
+type Matrix struct {
+	// Settings shared by all expanded servers. The comment is used as the name of the matrix.
+	Template Server `json:"template"`
+
+	// Images to test, i.e. "jellyfin/jellyfin" and "lscr.io/linuxserver/jellyfin".
+	Images []string `json:"images"`
+
+	// Tags to test every image with. If empty, images are used as is.
+	Tags []string `json:"tags"`
+
+	// Browsers to test separately. If empty, every expanded server tests all of the template's browsers.
+	Browsers []string `json:"browsers"`
+}
+
+// Manual test which pauses testing until the tester enters its outcome.
+type Checkpoint struct {
+	// What the tester should check, i.e. "Skip button is shown during the introduction".
+	Description string `json:"description"`
+
+	// How long to wait for an outcome, i.e. "5m". Waits indefinitely if not set.
+	Timeout string `json:"timeout"`
+
+	// Continue without an outcome when the timeout elapses or there is no input, instead of failing the checkpoint.
+	AutoContinue bool `json:"auto_continue"`
+}
+
+// Parses the checkpoint's timeout. Returns zero if no timeout is set.
+func (c Checkpoint) timeout() (time.Duration, error) {
+	if c.Timeout == "" {
+		return 0, nil
+	}
+
+	return time.ParseDuration(c.Timeout)
+}
+
+type Thresholds struct {
+	// Maximum number of seconds that timestamps can differ by before they are considered changed. Defaults to 5.
+	Tolerance *int `json:"tolerance"`
+
+	// Maximum number of introductions which can be lost, changed or gained. Unlimited if not set.
+	MaxLost    *int `json:"max_lost"`
+	MaxChanged *int `json:"max_changed"`
+	MaxGained  *int `json:"max_gained"`
+
+	// Minimum percentage of episodes which must be okay or improved.
+	MinOkay float64 `json:"min_okay"`
+
+	// Maximum percentage that the analysis runtime, average CPU usage and peak memory usage may increase by.
+	// Unlimited if not set.
+	MaxRuntimeIncrease *float64 `json:"max_runtime_increase"`
+	MaxCPUIncrease     *float64 `json:"max_cpu_increase"`
+	MaxMemoryIncrease  *float64 `json:"max_memory_increase"`
 }
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// Prints a table with the status and details or error of every stage of every tested server.
+func printSummary(w io.Writer, results []ServerResult) {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(table, "SERVER\tSTAGE\tSTATUS\tDURATION\tDETAILS")
+
+	for _, server := range results {
+		for _, stage := range server.Stages {
+			message := stage.Details
+			if stage.Err != nil && stage.Status == StageFailed {
+				message = stage.Err.Error()
+				if stage.Details != "" {
+					message = stage.Details + ": " + message
+				}
+			}
+
+			fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n",
+				server.Name,
+				stage.Name,
+				stage.Status,
+				stage.Duration.Round(time.Millisecond),
+				redact(message))
+		}
+	}
+
+	table.Flush()
+
+	printCheckpoints(w, results)
+}
+
+// Prints a table with the outcome and note of every manual checkpoint, if any server had checkpoints.
+func printCheckpoints(w io.Writer, results []ServerResult) {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	header := false
+
+	for _, server := range results {
+		for _, checkpoint := range server.Checkpoints {
+			if !header {
+				fmt.Fprintln(table)
+				fmt.Fprintln(table, "SERVER\tCHECKPOINT\tOUTCOME\tNOTE")
+				header = true
+			}
+
+			fmt.Fprintf(table, "%s\t%s\t%s\t%s\n",
+				server.Name,
+				checkpoint.Description,
+				checkpoint.Outcome,
+				redact(checkpoint.Note))
+		}
+	}
+
+	table.Flush()
+}
+
+// JUnit XML report. Each server is a test suite and each stage and manual checkpoint is a test case.
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     float64          `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      float64         `xml:"time,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+}
+
+// Converts the results of all servers into a JUnit report.
+func junitReport(results []ServerResult) junitTestSuites {
+	var report junitTestSuites
+
+	for _, server := range results {
+		suite := junitTestSuite{Name: server.Name}
+
+		for _, stage := range server.Stages {
+			testCase := junitTestCase{
+				Name:      stage.Name,
+				ClassName: server.Name,
+				Time:      stage.Duration.Seconds(),
+				SystemOut: redact(stage.Details),
+			}
+
+			message := ""
+			if stage.Err != nil {
+				message = redact(stage.Err.Error())
+			}
+
+			switch stage.Status {
+			case StageFailed:
+				testCase.Failure = &junitMessage{Message: message}
+				suite.Failures++
+
+			case StageSkipped:
+				testCase.Skipped = &junitMessage{Message: message}
+				suite.Skipped++
+			}
+
+			suite.Tests++
+			suite.Time += testCase.Time
+			suite.TestCases = append(suite.TestCases, testCase)
+		}
+
+		for _, checkpoint := range server.Checkpoints {
+			testCase := junitTestCase{
+				Name:      "checkpoint: " + checkpoint.Description,
+				ClassName: server.Name,
+				Time:      checkpoint.Duration.Seconds(),
+				SystemOut: redact(checkpoint.Note),
+			}
+
+			switch checkpoint.Outcome {
+			case CheckpointFailed:
+				testCase.Failure = &junitMessage{Message: redact(checkpoint.Note)}
+				suite.Failures++
+
+			case CheckpointSkipped:
+				testCase.Skipped = &junitMessage{Message: redact(checkpoint.Note)}
+				suite.Skipped++
+			}
+
+			suite.Tests++
+			suite.Time += testCase.Time
+			suite.TestCases = append(suite.TestCases, testCase)
+		}
+
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Skipped += suite.Skipped
+		report.Time += suite.Time
+		report.Suites = append(report.Suites, suite)
+	}
+
+	return report
+}
+
+// Writes the results of all servers to a JUnit XML file.
+func writeJUnit(path string, results []ServerResult) error {
+	marshalled, err := xml.MarshalIndent(junitReport(results), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append([]byte(xml.Header), marshalled...), 0600)
+}
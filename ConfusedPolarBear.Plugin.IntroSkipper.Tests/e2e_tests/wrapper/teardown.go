@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// Canceled once the wrapper is interrupted. External programs are killed when it is canceled unless they were started
+// with another context.
+var runContext = context.Background()
+
+// Returns true if the wrapper was interrupted.
+func interrupted() bool {
+	return runContext.Err() != nil
+}
+
+// Cancels the run context when the wrapper receives SIGINT or SIGTERM, so that running programs are killed and all
+// remaining stages except artifacts and teardown are skipped. A second signal exits immediately without cleaning up.
+// Returns a function which stops handling signals.
+func handleInterrupts() func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	runContext = ctx
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-signals:
+			rootLog.Warnf("Received %s, stopping all servers. Interrupt again to exit without cleaning up", sig)
+			signal.Reset(os.Interrupt, syscall.SIGTERM)
+			cancel()
+
+		case <-done:
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+		cancel()
+	}
+}
+
+// An action which undoes something that was setup, such as stopping a container.
+type teardownAction struct {
+	id   int
+	name string
+	run  func() error
+}
+
+// Actions which have been registered but have not run yet, in the order they were registered in.
+var teardowns struct {
+	sync.Mutex
+	next    int
+	pending []teardownAction
+}
+
+// Registers an action which must run before the wrapper exits, even if a stage or the wrapper itself panics. Returns
+// an id which is used to run the action as soon as the resource is no longer needed.
+func registerTeardown(name string, action func() error) int {
+	teardowns.Lock()
+	defer teardowns.Unlock()
+
+	teardowns.next++
+	teardowns.pending = append(teardowns.pending, teardownAction{id: teardowns.next, name: name, run: action})
+
+	return teardowns.next
+}
+
+// Removes a registered action and returns it, if it has not run yet.
+func takeTeardown(id int) (teardownAction, bool) {
+	teardowns.Lock()
+	defer teardowns.Unlock()
+
+	for i, action := range teardowns.pending {
+		if action.id == id {
+			teardowns.pending = append(teardowns.pending[:i], teardowns.pending[i+1:]...)
+			return action, true
+		}
+	}
+
+	return teardownAction{}, false
+}
+
+// Runs a registered action. Every action is only run once, so this does nothing if it already ran.
+func runTeardown(id int) error {
+	action, ok := takeTeardown(id)
+	if !ok {
+		return nil
+	}
+
+	return action.run()
+}
+
+// Runs every action which has not run yet, most recently registered first. Errors are logged and do not stop the
+// remaining actions from running.
+func runAllTeardowns(log *Logger) {
+	for {
+		teardowns.Lock()
+		if len(teardowns.pending) == 0 {
+			teardowns.Unlock()
+			return
+		}
+
+		last := len(teardowns.pending) - 1
+		action := teardowns.pending[last]
+		teardowns.pending = teardowns.pending[:last]
+		teardowns.Unlock()
+
+		log.Warnf("Cleaning up %s", action.name)
+		if err := action.run(); err != nil {
+			log.Errorf("Unable to clean up %s: %s", action.name, err)
+		}
+	}
+}
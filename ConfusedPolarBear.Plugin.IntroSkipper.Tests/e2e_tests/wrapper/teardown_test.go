@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Replaces the run context until the test finishes. Returns a function which interrupts the test.
+func interruptTest(t *testing.T) context.CancelFunc {
+	old := runContext
+	t.Cleanup(func() { runContext = old })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	runContext = ctx
+
+	return cancel
+}
+
+// Discards all registered teardown actions when the test finishes.
+func isolateTeardowns(t *testing.T) {
+	teardowns.Lock()
+	old := teardowns.pending
+	teardowns.pending = nil
+	teardowns.Unlock()
+
+	t.Cleanup(func() {
+		teardowns.Lock()
+		teardowns.pending = old
+		teardowns.Unlock()
+	})
+}
+
+func TestTeardownRegistry(t *testing.T) {
+	captureLog(t)
+	isolateTeardowns(t)
+
+	var ran []string
+	register := func(name string, err error) int {
+		return registerTeardown(name, func() error {
+			ran = append(ran, name)
+			return err
+		})
+	}
+
+	container := register("container", nil)
+	register("selenium", nil)
+	register("directory", errors.New("busy"))
+
+	// Actions only run once, whether they are run early or during cleanup
+	if err := runTeardown(container); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runTeardown(container); err != nil {
+		t.Fatal(err)
+	}
+
+	runAllTeardowns(rootLog)
+	runAllTeardowns(rootLog)
+
+	if strings.Join(ran, ",") != "container,directory,selenium" {
+		t.Errorf("actions ran in the wrong order: %v", ran)
+	}
+}
+
+func TestInterruptedStages(t *testing.T) {
+	captureLog(t)
+	interruptTest(t)()
+
+	var ran []string
+	stage := func(name string, always bool) Stage {
+		return Stage{Name: name, Always: always, Run: func(*serverRun) error {
+			ran = append(ran, name)
+			return nil
+		}}
+	}
+
+	result := runStages(&serverRun{log: rootLog}, []Stage{stage("analyze", false), stage("teardown", true)})
+
+	if strings.Join(ran, ",") != "teardown" {
+		t.Errorf("incorrect stages were run after an interrupt: %v", ran)
+	}
+
+	if result.Stages[0].Status != StageSkipped || !strings.Contains(result.Stages[0].Err.Error(), "interrupted") {
+		t.Errorf("stage was not skipped: %+v", result.Stages[0])
+	}
+}
+
+func TestTeardownAfterInterrupt(t *testing.T) {
+	setupContainerTest(t)
+	isolateTeardowns(t)
+	interrupt := interruptTest(t)
+
+	runtime := newFakeRuntime()
+	run := &serverRun{
+		server:  Server{Comment: "local", Image: "jellyfin/jellyfin", Docker: true},
+		log:     rootLog,
+		runtime: runtime,
+	}
+
+	// The wrapper is interrupted while waiting for the container to finish starting
+	interrupt()
+	if err := stageContainerStart(run); err == nil || !strings.Contains(err.Error(), "interrupted") {
+		t.Errorf("startup was not interrupted: %v", err)
+	}
+
+	if run.configurationDirectory == "" {
+		t.Fatal("container was not started")
+	}
+
+	if err := stageTeardown(run); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(run.configurationDirectory); !os.IsNotExist(err) {
+		t.Error("configuration directory was not deleted")
+	}
+
+	if calls := strings.Join(runtime.Calls, ","); !strings.Contains(calls, "stop "+run.server.ContainerName) {
+		t.Errorf("container was not stopped: %s", calls)
+	}
+
+	// The container was already cleaned up by its stage
+	teardowns.Lock()
+	defer teardowns.Unlock()
+	if len(teardowns.pending) != 0 {
+		t.Errorf("teardown is still pending: %+v", teardowns.pending)
+	}
+}
+
+func TestRemoveContainers(t *testing.T) {
+	captureLog(t)
+
+	runtime := newFakeRuntime()
+	runtime.running["jf-e2e-100-0"] = ContainerSpec{}
+	runtime.running["renamed"] = ContainerSpec{Labels: map[string]string{containerLabel: "true"}}
+	runtime.running["my-jf-e2e-container"] = ContainerSpec{}
+	runtime.running["jf-e2e-100-1"] = ContainerSpec{Labels: map[string]string{containerLabel: "true"}}
+	runtime.running["jf-e2e"] = ContainerSpec{}
+
+	if err := removeContainers(rootLog, runtime); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := runtime.running["jf-e2e-100-0"]; ok {
+		t.Error("unlabeled container was not removed")
+	}
+
+	if _, ok := runtime.running["jf-e2e-100-1"]; ok {
+		t.Error("labeled container was not removed")
+	}
+
+	// Containers started before servers were given unique names have neither a label nor a suffix
+	if _, ok := runtime.running["jf-e2e"]; ok {
+		t.Error("container from an older run was not removed")
+	}
+
+	// Labeled containers are removed regardless of their name
+	if _, ok := runtime.running["renamed"]; ok {
+		t.Error("renamed labeled container was not removed")
+	}
+
+	// Containers which were not named by us are left alone
+	if _, ok := runtime.running["my-jf-e2e-container"]; !ok {
+		t.Error("unrelated container was removed")
+	}
+}
+
+func TestRemoveConfigurationDirectories(t *testing.T) {
+	captureLog(t)
+
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "jf-e2e-123", "data", "plugins", "intro-skipper", "plugin.dll"), "")
+	writeTestFile(t, filepath.Join(root, "other", "file"), "")
+
+	if err := removeConfigurationDirectories(rootLog, root); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "jf-e2e-123")); !os.IsNotExist(err) {
+		t.Error("configuration directory was not deleted")
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "other")); err != nil {
+		t.Error("unrelated directory was deleted")
+	}
+}
+
+func TestAPIRequestInterrupted(t *testing.T) {
+	captureLog(t)
+	interrupt := interruptTest(t)
+
+	// Server which never responds
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		interrupt()
+	}()
+
+	start := time.Now()
+	if _, err := apiRequest(rootLog, "GET", server.URL+"/System/Info/Public", ""); !errors.Is(err, context.Canceled) {
+		t.Errorf("request was not canceled: %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("request took %s to be canceled", elapsed)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Scenario which analyzes episodes with an old version of the plugin, upgrades it and checks that nothing was lost.
+const scenarioUpgrade = "upgrade"
+
+// Category of the plugin's scheduled tasks.
+const pluginTaskCategory = "Intro Skipper"
+
+// All stages used to test upgrading the plugin, in the order they are run.
+var upgradeStages = buildUpgradeStages(serverStages)
+
+// Builds the upgrade stages from the regular stages by upgrading the plugin and comparing the results right after
+// analysis. Upgrades always copy the plugin and are compared against the results from before the upgrade instead of
+// a baseline, so the install and compare stages are left out.
+func buildUpgradeStages(stages []Stage) []Stage {
+	var upgrade []Stage
+
+	for _, stage := range stages {
+		if stage.Name == "install" || stage.Name == "compare" {
+			continue
+		}
+
+		upgrade = append(upgrade, stage)
+
+		if stage.Name == "analyze" {
+			upgrade = append(upgrade,
+				Stage{Name: "upgrade", Run: stageUpgrade, Commands: describeUpgrade},
+				Stage{Name: "compare upgrade", Run: stageCompareUpgrade, Test: true, Commands: describeCompareUpgrade})
+		}
+	}
+
+	return upgrade
+}
+
+// Returns the stages used to test a server.
+func stagesFor(server Server) []Stage {
+	if server.Scenario == scenarioUpgrade {
+		return upgradeStages
+	}
+
+	return serverStages
+}
+
+// Returns the plugin DLL to install when a local server's container is first started. Returns an empty string if
+// the plugin is installed after the server is setup.
+func initialPlugin(server Server) string {
+	if server.Scenario == scenarioUpgrade {
+		return server.UpgradeFrom
+	} else if server.Install == installRepository {
+		return ""
+	}
+
+	return pluginPath
+}
+
+// Last run of a scheduled task.
+type taskExecution struct {
+	StartTimeUtc string
+	EndTimeUtc   string
+	Status       string
+}
+
+// State of a scheduled task, as returned by /ScheduledTasks.
+type scheduledTask struct {
+	Name                string
+	Key                 string
+	Category            string
+	State               string
+	LastExecutionResult *taskExecution
+
+	// Only set while the task is running.
+	CurrentProgressPercentage *float64
+}
+
+// Returns all of the plugin's scheduled tasks.
+func pluginTasks(run *serverRun) ([]scheduledTask, error) {
+	return fetchPluginTasks(run.log, run.server.Address, run.apiKey)
+}
+
+// Returns all of the plugin's scheduled tasks, logging the request to log.
+func fetchPluginTasks(log *Logger, address, apiKey string) ([]scheduledTask, error) {
+	var tasks []scheduledTask
+	url := fmt.Sprintf("%s/ScheduledTasks?api_key=%s", address, apiKey)
+	if err := apiJSON(log, "GET", url, "", &tasks); err != nil {
+		return nil, err
+	}
+
+	var plugin []scheduledTask
+	for _, task := range tasks {
+		if task.Category == pluginTaskCategory {
+			plugin = append(plugin, task)
+		}
+	}
+
+	return plugin, nil
+}
+
+// Returns a description of every plugin task which is running or has run since the before snapshot was taken.
+func detectReanalysis(before, after []scheduledTask) []string {
+	previous := make(map[string]*taskExecution)
+	for _, task := range before {
+		previous[task.Key] = task.LastExecutionResult
+	}
+
+	var problems []string
+	for _, task := range after {
+		if task.State != "" && task.State != "Idle" {
+			problems = append(problems, fmt.Sprintf("%s is %s", task.Name, strings.ToLower(task.State)))
+			continue
+		}
+
+		last, old := task.LastExecutionResult, previous[task.Key]
+		if last == nil {
+			continue
+		}
+
+		if old == nil || last.StartTimeUtc != old.StartTimeUtc {
+			problems = append(problems, fmt.Sprintf("%s ran at %s", task.Name, last.StartTimeUtc))
+		}
+	}
+
+	return problems
+}
+
+// Replaces the old plugin with the plugin under test and restarts the server.
+func stageUpgrade(run *serverRun) error {
+	tasks, err := pluginTasks(run)
+	if err != nil {
+		return fmt.Errorf("failed to get scheduled tasks: %w", err)
+	}
+
+	run.tasksBeforeUpgrade = tasks
+
+	run.log.Infof("Upgrading plugin from %s to %s", run.server.UpgradeFrom, pluginPath)
+	if err := installPlugin(run.log, run.runtime, run.server, run.configurationDirectory, pluginPath); err != nil {
+		return err
+	}
+
+	if err := run.runtime.Restart(run.server.ContainerName); err != nil {
+		return fmt.Errorf("failed to restart container: %w", err)
+	}
+
+	time.Sleep(time.Second)
+	return waitForServerReady(run)
+}
+
+// Returns the paths that the report saved after upgrading and its comparison against the original report are saved to.
+func upgradeReportPaths(run *serverRun) (string, string) {
+	upgradedReport := strings.TrimSuffix(run.reportPath, ".json") + "-upgraded.json"
+	return upgradedReport, strings.TrimSuffix(upgradedReport, ".json") + ".html"
+}
+
+// Returns the verifier commands used to save the timestamps returned by the upgraded plugin and to compare them
+// against the timestamps returned before upgrading.
+func compareUpgradeCommands(run *serverRun) (Command, Command) {
+	upgradedReport, comparison := upgradeReportPaths(run)
+
+	save := verifierCommand(
+		"-address", run.server.Address,
+		"-key", run.apiKey,
+		"-keep",
+		"-o", upgradedReport)
+
+	// Timestamps must be identical
+	exact := 0
+	thresholds := Thresholds{Tolerance: &exact, MaxLost: &exact, MaxChanged: &exact, MaxGained: &exact}
+
+	compare := verifierCommand(comparisonArguments(run.reportPath, upgradedReport, comparison, thresholds)...)
+
+	return save, compare
+}
+
+// Checks that the upgraded plugin returns exactly the same timestamps as the old plugin and did not reanalyze.
+func stageCompareUpgrade(run *serverRun) error {
+	_, comparison := upgradeReportPaths(run)
+	save, compare := compareUpgradeCommands(run)
+
+	// Save the timestamps returned by the upgraded plugin without erasing them
+	run.log.Infof("Saving timestamps after upgrade")
+	result := save.Run(ProgramOptions{Log: run.log, Timeout: time.Minute})
+	if !result.Success() {
+		return result.Err
+	}
+
+	run.log.Infof("Comparing timestamps before and after upgrade")
+	result = compare.Run(ProgramOptions{Log: run.log, Timeout: time.Minute})
+
+	run.details = fmt.Sprintf("see %s", comparison)
+	if !result.Success() {
+		return fmt.Errorf("timestamps changed after upgrading: %w", result.Err)
+	}
+
+	// Analysis must not have been started by the upgrade
+	tasks, err := pluginTasks(run)
+	if err != nil {
+		return fmt.Errorf("failed to get scheduled tasks: %w", err)
+	}
+
+	if problems := detectReanalysis(run.tasksBeforeUpgrade, tasks); len(problems) > 0 {
+		return fmt.Errorf("upgrade triggered reanalysis: %s", strings.Join(problems, ", "))
+	}
+
+	return nil
+}
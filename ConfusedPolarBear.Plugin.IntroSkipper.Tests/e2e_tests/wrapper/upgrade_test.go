@@ -0,0 +1,127 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDetectReanalysis(t *testing.T) {
+	ran := &taskExecution{StartTimeUtc: "2022-10-01T00:00:00Z"}
+	before := []scheduledTask{
+		{Name: "Detect Introductions", Key: "intros", State: "Idle", LastExecutionResult: ran},
+		{Name: "Detect Credits", Key: "credits", State: "Idle"},
+	}
+
+	if problems := detectReanalysis(before, before); len(problems) != 0 {
+		t.Errorf("unchanged tasks were reported: %v", problems)
+	}
+
+	after := []scheduledTask{
+		{Name: "Detect Introductions", Key: "intros", State: "Running", LastExecutionResult: ran},
+		{Name: "Detect Credits", Key: "credits", State: "Idle",
+			LastExecutionResult: &taskExecution{StartTimeUtc: "2022-10-02T00:00:00Z"}},
+	}
+
+	expected := []string{"Detect Introductions is running", "Detect Credits ran at 2022-10-02T00:00:00Z"}
+	if problems := detectReanalysis(before, after); !reflect.DeepEqual(problems, expected) {
+		t.Errorf("incorrect problems: %v", problems)
+	}
+}
+
+func TestUpgradeStages(t *testing.T) {
+	upgrade := Server{Scenario: scenarioUpgrade, UpgradeFrom: "old.dll"}
+
+	hasStage := func(stages []Stage, name string) bool {
+		for _, stage := range stages {
+			if stage.Name == name {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if !hasStage(stagesFor(upgrade), "upgrade") || hasStage(stagesFor(Server{}), "upgrade") {
+		t.Error("incorrect stages selected")
+	}
+
+	var names []string
+	for _, stage := range upgradeStages {
+		names = append(names, stage.Name)
+	}
+
+	expected := "container start, setup, login, configure, scan, analyze, upgrade, compare upgrade, verify, " +
+		"checkpoints, ui tests, artifacts, teardown"
+
+	if actual := strings.Join(names, ", "); actual != expected {
+		t.Errorf("incorrect upgrade stages: %s", actual)
+	}
+
+	if initialPlugin(upgrade) != "old.dll" || initialPlugin(Server{}) != pluginPath {
+		t.Error("incorrect plugin installed initially")
+	}
+}
+
+func TestStageUpgrade(t *testing.T) {
+	captureLog(t)
+	setupContainerTest(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/System/Info/Public", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/ScheduledTasks", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[
+			{"Name": "Scan Media Library", "Key": "RefreshLibrary", "Category": "Library", "State": "Idle"},
+			{"Name": "Detect Introductions", "Key": "CPBIntroSkipperDetectIntroductions", "Category": "Intro Skipper", "State": "Idle"}
+		]`))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// Install the old plugin
+	oldPlugin := path.Join(configurationRoot, "old.dll")
+	if err := os.WriteFile(oldPlugin, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	runtime := newFakeRuntime()
+	run := &serverRun{
+		server:  Server{Address: server.URL, Image: "jellyfin/jellyfin", ContainerName: "jf-e2e-upgrade", UpgradeFrom: oldPlugin},
+		log:     rootLog,
+		runtime: runtime,
+	}
+
+	directory, err := startContainer(run.log, runtime, run.server, testLibraries, oldPlugin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run.configurationDirectory = directory
+
+	if err := stageUpgrade(run); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the new plugin should be installed
+	installed, err := os.ReadDir(pluginDirectory(run.server, directory))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(installed) != 1 || installed[0].Name() != "plugin.dll" {
+		t.Errorf("old plugin was not replaced: %v", installed)
+	}
+
+	if !reflect.DeepEqual(runtime.Calls, []string{"run jf-e2e-upgrade", "restart jf-e2e-upgrade"}) {
+		t.Errorf("unexpected runtime calls: %v", runtime.Calls)
+	}
+
+	if len(run.tasksBeforeUpgrade) != 1 || run.tasksBeforeUpgrade[0].Key != "CPBIntroSkipperDetectIntroductions" {
+		t.Errorf("plugin tasks were not recorded: %+v", run.tasksBeforeUpgrade)
+	}
+}